@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultCatalog is the built-in source catalog used when no SOURCES_FILE is given
+//
+//go:embed sources.json
+var defaultCatalog []byte
+
+// SourceSpec describes a news source in the catalog: its RSS feeds and the
+// pages to scrape when the feeds return nothing
+type SourceSpec struct {
+	Name                 string       `json:"name"`
+	Enabled              bool         `json:"enabled"`
+	Feeds                []string     `json:"feeds"`
+	Pages                []string     `json:"pages"`
+	ArticleSelector      string       `json:"article_selector"`
+	TitleSelectors       []string     `json:"title_selectors"`
+	LinkSelector         string       `json:"link_selector"` // Empty means the title element carries the link
+	DescriptionSelectors []string     `json:"description_selectors"`
+	DateSelector         string       `json:"date_selector"`       // Element holding the publish date in a listing, defaults to time[datetime]
+	FetchArticleDates    bool         `json:"fetch_article_dates"` // Open each article to read its date when the listing has none
+	BaseURL              string       `json:"base_url"`            // Used to resolve relative links, defaults to the page URL
+	MaxItems             int          `json:"max_items"`
+	Filter               FilterPolicy `json:"filter"` // What to do with items not mentioning Spain, defaults to score_only
+}
+
+// LoadCatalog reads the source catalog from a JSON file, or the built-in one if path is empty
+func LoadCatalog(path string) ([]SourceSpec, error) {
+	data := defaultCatalog
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading source catalog: %v", err)
+		}
+	}
+
+	var specs []SourceSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("error parsing source catalog: %v", err)
+	}
+
+	names := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("source catalog entry %d has no name", i)
+		}
+		// Sources are keyed by name, so a second entry would replace the first
+		if names[spec.Name] {
+			return nil, fmt.Errorf("source %q is listed more than once", spec.Name)
+		}
+		names[spec.Name] = true
+		if len(spec.Feeds) == 0 && len(spec.Pages) == 0 {
+			return nil, fmt.Errorf("source %q has neither feeds nor pages", spec.Name)
+		}
+		if len(spec.Pages) > 0 && (spec.ArticleSelector == "" || len(spec.TitleSelectors) == 0) {
+			return nil, fmt.Errorf("source %q needs article and title selectors to scrape pages", spec.Name)
+		}
+		if err := spec.Filter.validate(); err != nil {
+			return nil, fmt.Errorf("source %q: %v", spec.Name, err)
+		}
+	}
+
+	return specs, nil
+}
+
+// catalogSource is a Source driven by a catalog entry
+type catalogSource struct {
+	na   *NewsAggregator
+	spec SourceSpec
+}
+
+func (s *catalogSource) Name() string               { return s.spec.Name }
+func (s *catalogSource) Kind() SourceKind           { return SourceKindNews }
+func (s *catalogSource) FilterPolicy() FilterPolicy { return s.spec.Filter }
+
+func (s *catalogSource) Fetch(ctx context.Context) (SourceResult, error) {
+	news, err := s.na.fetchFromSpec(ctx, s.spec)
+	return SourceResult{News: news}, err
+}
+
+// RegisterCatalog registers every enabled catalog entry as a news source
+func (na *NewsAggregator) RegisterCatalog(specs []SourceSpec) {
+	for _, spec := range specs {
+		if !spec.Enabled {
+			continue
+		}
+		na.RegisterSource(&catalogSource{na: na, spec: spec})
+	}
+}
+
+// fetchFromSpec reads the source's feeds and falls back to scraping its pages
+func (na *NewsAggregator) fetchFromSpec(ctx context.Context, spec SourceSpec) ([]NewsItem, error) {
+	var news []NewsItem
+	var lastErr error
+
+	for _, feedURL := range spec.Feeds {
+		items, err := na.fetchRSSFeed(ctx, feedURL, spec.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Error fetching %s feed from %s: %v", spec.Name, feedURL, err)
+			lastErr = err
+			continue
+		}
+		news = append(news, items...)
+	}
+
+	if len(news) == 0 && len(spec.Pages) > 0 {
+		scraped, err := na.scrapePages(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		news = scraped
+	} else if len(news) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	detectItemLanguages(news)
+	return news, nil
+}
+
+// scrapePages extracts news items from the source's listing pages using its selectors
+func (na *NewsAggregator) scrapePages(ctx context.Context, spec SourceSpec) ([]NewsItem, error) {
+	var news []NewsItem
+	var lastErr error
+	scrapedPages := 0
+
+	dateSelector := spec.DateSelector
+	if dateSelector == "" {
+		dateSelector = defaultDateSelector
+	}
+
+	for _, pageURL := range spec.Pages {
+		doc, err := na.fetchDocument(ctx, pageURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Error scraping %s page %s: %v", spec.Name, pageURL, err)
+			lastErr = err
+			continue
+		}
+		scrapedPages++
+
+		base := spec.BaseURL
+		if base == "" {
+			base = pageURL
+		}
+
+		pageLang := normalizeLangCode(doc.Find("html").AttrOr("lang", ""))
+
+		doc.Find(spec.ArticleSelector).Each(func(i int, s *goquery.Selection) {
+			if spec.MaxItems > 0 && len(news) >= spec.MaxItems {
+				return
+			}
+
+			titleElem := findFirst(s, spec.TitleSelectors)
+			title := cleanText(titleElem.Text())
+
+			linkElem := titleElem
+			if spec.LinkSelector != "" {
+				linkElem = s.Find(spec.LinkSelector).First()
+			}
+			href, _ := linkElem.Attr("href")
+			link := resolveLink(base, href)
+
+			description := cleanText(findFirst(s, spec.DescriptionSelectors).Text())
+
+			publishDate, dateKnown := dateFromSelection(s, dateSelector)
+
+			if title != "" && link != "" {
+				news = append(news, NewsItem{
+					Title:       title,
+					Description: description,
+					Link:        link,
+					Source:      spec.Name,
+					Language:    pageLang,
+					PublishDate: publishDate,
+					DateUnknown: !dateKnown,
+				})
+			}
+		})
+	}
+
+	if scrapedPages == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	if spec.FetchArticleDates {
+		na.fillArticleDates(ctx, news)
+	}
+	return dropStale(news), nil
+}
+
+// fetchDocument downloads and parses an HTML page
+func (na *NewsAggregator) fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	body, err := na.fetchPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(bytes.NewReader(body))
+}
+
+// fetchPage downloads a page
+func (na *NewsAggregator) fetchPage(ctx context.Context, pageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", na.config.UserAgent)
+	resp, err := na.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// findFirst returns the first non-empty match, trying selectors in order
+func findFirst(s *goquery.Selection, selectors []string) *goquery.Selection {
+	for _, selector := range selectors {
+		elem := s.Find(selector).First()
+		if elem.Length() > 0 && strings.TrimSpace(elem.Text()) != "" {
+			return elem
+		}
+	}
+	return s.Slice(0, 0)
+}
+
+// resolveLink turns a possibly relative href into an absolute URL
+func resolveLink(base, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return baseURL.ResolveReference(ref).String()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		err     string
+	}{
+		{"valid", `[{"name": "El País", "feeds": ["https://elpais.com/rss"]}, {"name": "BBC", "feeds": ["https://bbc.co.uk/rss"]}]`, ""},
+		{"no name", `[{"feeds": ["https://elpais.com/rss"]}]`, "entry 0 has no name"},
+		{"duplicate name", `[{"name": "El País", "feeds": ["https://elpais.com/rss"]}, {"name": "El País", "feeds": ["https://elpais.com/rss2"]}]`, `"El País" is listed more than once`},
+		{"nothing to fetch", `[{"name": "El País"}]`, "neither feeds nor pages"},
+		{"pages without selectors", `[{"name": "El País", "pages": ["https://elpais.com"]}]`, "needs article and title selectors"},
+		{"unknown filter", `[{"name": "El País", "feeds": ["https://elpais.com/rss"], "filter": "sometimes"}]`, "El País"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sources.json")
+			if err := os.WriteFile(path, []byte(tt.catalog), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadCatalog(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := LoadCatalog(""); err != nil {
+		t.Errorf("built-in catalog: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runHistoryCommand implements the "history" subcommand used to inspect or prune the delivery history
+func runHistoryCommand(config Config, args []string) error {
+	if config.HistoryFile == "" {
+		return fmt.Errorf("delivery history is disabled (history_file is empty)")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: history list [-n N] | history prune [-older-than DURATION]")
+	}
+
+	history, err := OpenHistory(config.HistoryFile)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("history list", flag.ContinueOnError)
+		limit := fs.Int("n", 50, "number of entries to show, 0 for all")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		entries := history.Entries()
+		if *limit > 0 && len(entries) > *limit {
+			entries = entries[:*limit]
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SENT AT\tREGION\tSOURCE\tTITLE\tLINK")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				e.SentAt.Local().Format("2006-01-02 15:04"), e.Region, e.Source, truncateString(e.Title, 60), e.Link)
+		}
+		w.Flush()
+		fmt.Printf("%d of %d entries\n", len(entries), len(history.entries))
+		return nil
+
+	case "prune":
+		fs := flag.NewFlagSet("history prune", flag.ContinueOnError)
+		olderThan := fs.Duration("older-than", config.HistoryWindow.Duration, "remove entries sent longer ago than this")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		removed := history.Prune(time.Now().Add(-*olderThan))
+		if err := history.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d entries older than %s, %d left\n", removed, *olderThan, len(history.entries))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown history command %q", args[0])
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// StoryReport is one outlet's report of a story that was merged into another item
+type StoryReport struct {
+	Title  string `json:"title"`
+	Link   string `json:"link"`
+	Source string `json:"source"`
+}
+
+// spanishStopwords are left out when comparing headlines
+var spanishStopwords = map[string]bool{
+	"el": true, "la": true, "los": true, "las": true, "un": true, "una": true, "unos": true, "unas": true,
+	"de": true, "del": true, "al": true, "a": true, "en": true, "y": true, "e": true, "o": true, "u": true,
+	"que": true, "por": true, "para": true, "con": true, "sin": true, "se": true, "su": true, "sus": true,
+	"es": true, "lo": true, "le": true, "les": true, "como": true, "mas": true, "pero": true, "sobre": true,
+	"tras": true, "ante": true, "entre": true, "hasta": true, "desde": true, "ya": true, "no": true, "si": true,
+	"the": true, "of": true, "in": true, "and": true, "to": true, "for": true, "on": true, "is": true,
+}
+
+// accentFolder maps accented Spanish letters to their plain form
+var accentFolder = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+)
+
+// titleShingles turns a headline into a set of stemmed word shingles.
+// Words are folded to lower case without accents, stopwords are dropped and
+// each word is cut to its first five letters, a crude stem that lets
+// "presidente"/"presidenta" or "gobierno"/"gobiernos" match.
+func titleShingles(title string) map[string]bool {
+	title = accentFolder.Replace(strings.ToLower(title))
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	shingles := make(map[string]bool)
+	for _, w := range words {
+		if spanishStopwords[w] || len([]rune(w)) < 2 {
+			continue
+		}
+		if r := []rune(w); len(r) > 5 {
+			w = string(r[:5])
+		}
+		shingles[w] = true
+	}
+	return shingles
+}
+
+// jaccard returns the Jaccard similarity of two shingle sets
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// clusterStories groups items that cover the same story, either because they
+// share a normalized link or because their headlines are similar enough, and
+// returns one representative per story with the other outlets and their
+// reports attached
+func clusterStories(news []NewsItem, similarity float64) []NewsItem {
+	parent := make([]int, len(news))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[rj] = ri
+		}
+	}
+
+	byLink := make(map[string]int)
+	shingles := make([]map[string]bool, len(news))
+	for i, item := range news {
+		if key := normalizeLink(item.Link); key != "" {
+			if j, ok := byLink[key]; ok {
+				union(j, i)
+			} else {
+				byLink[key] = i
+			}
+		}
+		shingles[i] = titleShingles(item.Title)
+	}
+
+	if similarity > 0 {
+		for i := range news {
+			for j := i + 1; j < len(news); j++ {
+				if jaccard(shingles[i], shingles[j]) >= similarity {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	// Collect members per cluster, keeping the order of first appearance
+	var order []int
+	members := make(map[int][]int)
+	for i := range news {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	clustered := make([]NewsItem, 0, len(order))
+	for _, root := range order {
+		group := members[root]
+		best := group[0]
+		for _, i := range group[1:] {
+			if betterRepresentative(news[i], news[best]) {
+				best = i
+			}
+		}
+
+		rep := news[best]
+		seen := map[string]bool{rep.Source: true}
+		for _, i := range group {
+			if i == best {
+				continue
+			}
+			rep.MergedReports = append(rep.MergedReports, StoryReport{Title: news[i].Title, Link: news[i].Link, Source: news[i].Source})
+			if src := news[i].Source; !seen[src] {
+				seen[src] = true
+				rep.AlsoCoveredBy = append(rep.AlsoCoveredBy, src)
+			}
+		}
+		clustered = append(clustered, rep)
+	}
+	return clustered
+}
+
+// betterRepresentative reports whether a should represent a story instead of b:
+// the higher score wins, then the item with the longer description
+func betterRepresentative(a, b NewsItem) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return len(a.Description) > len(b.Description)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClusterStories(t *testing.T) {
+	tests := []struct {
+		name       string
+		news       []NewsItem
+		similarity float64
+		want       []string            // Titles of the representatives, in order
+		covered    map[string][]string // Representative title -> AlsoCoveredBy
+	}{
+		{
+			name: "distinct stories",
+			news: []NewsItem{
+				{Title: "El Gobierno aprueba los presupuestos", Link: "https://elpais.com/a", Source: "El País"},
+				{Title: "Incendio forestal en Valencia", Link: "https://abc.es/b", Source: "ABC"},
+			},
+			similarity: 0.5,
+			want:       []string{"El Gobierno aprueba los presupuestos", "Incendio forestal en Valencia"},
+		},
+		{
+			name: "same link",
+			news: []NewsItem{
+				{Title: "Titular uno", Link: "https://www.elpais.com/a?utm_source=rss", Source: "El País", Score: 10},
+				{Title: "Otro titular distinto", Link: "http://elpais.com/a/", Source: "El País Internacional", Score: 20},
+			},
+			want:    []string{"Otro titular distinto"},
+			covered: map[string][]string{"Otro titular distinto": {"El País"}},
+		},
+		{
+			name: "similar headlines",
+			news: []NewsItem{
+				{Title: "El presidente del Gobierno anuncia elecciones anticipadas", Link: "https://elpais.com/a", Source: "El País", Score: 30},
+				{Title: "La presidenta del Gobierno anuncia las elecciones anticipadas", Link: "https://bbc.com/b", Source: "BBC", Score: 30, Description: "más larga"},
+				{Title: "Sequía en Andalucía", Link: "https://abc.es/c", Source: "ABC"},
+			},
+			similarity: 0.5,
+			want:       []string{"La presidenta del Gobierno anuncia las elecciones anticipadas", "Sequía en Andalucía"},
+			covered:    map[string][]string{"La presidenta del Gobierno anuncia las elecciones anticipadas": {"El País"}},
+		},
+		{
+			name: "similarity disabled",
+			news: []NewsItem{
+				{Title: "El presidente anuncia elecciones", Link: "https://elpais.com/a", Source: "El País"},
+				{Title: "El presidente anuncia elecciones", Link: "https://bbc.com/b", Source: "BBC"},
+			},
+			similarity: 0,
+			want:       []string{"El presidente anuncia elecciones", "El presidente anuncia elecciones"},
+		},
+		{
+			name: "same outlet listed once",
+			news: []NewsItem{
+				{Title: "Huelga de transportes en Madrid", Link: "https://elpais.com/a", Source: "El País", Score: 50},
+				{Title: "Huelga de transportes en Madrid hoy", Link: "https://abc.es/a", Source: "ABC"},
+				{Title: "Huelga de transportes en Madrid mañana", Link: "https://abc.es/b", Source: "ABC"},
+			},
+			similarity: 0.5,
+			want:       []string{"Huelga de transportes en Madrid"},
+			covered:    map[string][]string{"Huelga de transportes en Madrid": {"ABC"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clusterStories(tt.news, tt.similarity)
+			var titles []string
+			merged := 0
+			for _, item := range got {
+				titles = append(titles, item.Title)
+				if want := tt.covered[item.Title]; !slices.Equal(item.AlsoCoveredBy, want) {
+					t.Errorf("%q AlsoCoveredBy = %v, want %v", item.Title, item.AlsoCoveredBy, want)
+				}
+				merged += len(item.MergedReports)
+			}
+			// Every report that is not a representative is kept on one
+			if want := len(tt.news) - len(tt.want); merged != want {
+				t.Errorf("got %d merged reports, want %d", merged, want)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("representatives = %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusteredStoryRecordedForEveryOutlet(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	elPais := NewsItem{Title: "Huelga de transportes en Madrid", Link: "https://elpais.com/a", Source: "El País", Score: 50}
+	bbc := NewsItem{Title: "Huelga de transportes en Madrid hoy", Link: "https://bbc.com/b", Source: "BBC"}
+
+	h, err := OpenHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Record("es", clusterStories([]NewsItem{elPais, bbc}, 0.5), now)
+
+	for _, item := range []NewsItem{elPais, bbc} {
+		if !h.WasSent("es", item, time.Hour, now) {
+			t.Errorf("%s report of a delivered story is not in the history", item.Source)
+		}
+	}
+	if e := h.Entries(); len(e) != 2 || slices.IndexFunc(e, func(e HistoryEntry) bool { return e.Source == "BBC" && e.Link == bbc.Link }) < 0 {
+		t.Errorf("history entries = %+v, want one per report with its own source", e)
+	}
+}
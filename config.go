@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Duration is a time.Duration that reads from JSON strings such as "30s" or "2m"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// DefaultConfig returns the configuration used when no CONFIG_FILE is given
+func DefaultConfig() Config {
+	return Config{
+		MaxNewsItems:         5,
+		RequestTimeout:       Duration{30 * time.Second},
+		UserAgent:            "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		MaxConcurrentFetches: 4,
+		SourceTimeout:        Duration{60 * time.Second},
+		RunTimeout:           Duration{2 * time.Minute},
+		HistoryFile:          "sent_history.json",
+		HistoryWindow:        Duration{72 * time.Hour},
+		ClusterSimilarity:    0.5,
+		Scoring:              DefaultScoringConfig(),
+		Translation: TranslationConfig{
+			Provider:          ProviderDeepL,
+			DeepLPlan:         "free",
+			LibreTranslateURL: "http://localhost:5000",
+			CacheFile:         "translation_cache.json",
+			CacheTTL:          Duration{7 * 24 * time.Hour},
+			MaxDescriptionLen: 300,
+		},
+		FullText: FullTextConfig{
+			Candidates: 10,
+			MinLength:  300,
+			MaxLength:  5000,
+			SummaryLen: 300,
+		},
+		Delivery: DeliveryConfig{
+			Attempts:     4,
+			BaseDelay:    Duration{2 * time.Second},
+			MaxDelay:     Duration{30 * time.Second},
+			OutboxFile:   "outbox.json",
+			OutboxMaxAge: Duration{48 * time.Hour},
+		},
+		MetricsFile:       "run_metrics.json",
+		Languages:         []string{"ru"},
+		Layout:            LayoutPerLanguage,
+		Channel:           ChannelGeneric,
+		TelegramParseMode: TelegramHTML,
+
+		DescriptionPreviewLen: 150,
+		digestTemplate:        template.Must(ParseDigestTemplate("")),
+	}
+}
+
+// LoadConfig reads a JSON config file on top of the defaults, or returns the defaults if path is empty
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading config: %v", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error parsing config: %v", err)
+	}
+
+	if config.MaxConcurrentFetches < 1 {
+		config.MaxConcurrentFetches = 1
+	}
+	if config.MaxNewsItems < 1 {
+		return config, fmt.Errorf("error in config: max_news_items must be at least 1")
+	}
+	if config.SourceTimeout.Duration <= 0 || config.RunTimeout.Duration <= 0 {
+		return config, fmt.Errorf("error in config: source_timeout and run_timeout must be longer than 0s")
+	}
+	if err := config.Translation.validate(); err != nil {
+		return config, fmt.Errorf("error in config: %v", err)
+	}
+	if err := config.Delivery.validate(); err != nil {
+		return config, fmt.Errorf("error in config: %v", err)
+	}
+	if config.Layout != LayoutPerLanguage && config.Layout != LayoutBilingual {
+		return config, fmt.Errorf("error in config: layout must be %q or %q", LayoutPerLanguage, LayoutBilingual)
+	}
+	if _, ok := channelMessageLimits[config.Channel]; !ok {
+		return config, fmt.Errorf("error in config: unknown channel %q", config.Channel)
+	}
+	if config.TelegramParseMode != TelegramHTML && config.TelegramParseMode != TelegramMarkdownV2 {
+		return config, fmt.Errorf("error in config: telegram_parse_mode must be %q or %q", TelegramHTML, TelegramMarkdownV2)
+	}
+	if config.Template != "" {
+		if config.digestTemplate, err = ParseDigestTemplate(config.Template); err != nil {
+			return config, fmt.Errorf("error in config: %v", err)
+		}
+	}
+	config.Scoring.normalize()
+	for i, lang := range config.Languages {
+		config.Languages[i] = strings.ToLower(strings.TrimSpace(lang))
+	}
+	return config, nil
+}
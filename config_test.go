@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigRejectsInvalidLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"defaults", `{}`, ""},
+		{"zero source timeout", `{"source_timeout": "0s"}`, "source_timeout and run_timeout"},
+		{"negative run timeout", `{"run_timeout": "-1m"}`, "source_timeout and run_timeout"},
+		{"no news items", `{"max_news_items": 0}`, "max_news_items"},
+		{"negative news items", `{"max_news_items": -1}`, "max_news_items"},
+		{"negative base delay", `{"delivery": {"base_delay": "-1s"}}`, "must not be negative"},
+		{"negative outbox age", `{"delivery": {"outbox_max_age": "-1h"}}`, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxNewsAge is how old an item may be to make it into the digest
+const maxNewsAge = 24 * time.Hour
+
+// defaultDateSelector finds machine-readable dates in listing pages
+const defaultDateSelector = "time[datetime]"
+
+// dateLayouts are the formats tried when parsing dates found in HTML
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseDate parses a date in any of the formats news sites commonly use
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dateFromSelection reads a date from the first element matching selector,
+// looking at its datetime and content attributes before its text
+func dateFromSelection(s *goquery.Selection, selector string) (time.Time, bool) {
+	elem := s.Find(selector).First()
+	if elem.Length() == 0 {
+		return time.Time{}, false
+	}
+	for _, attr := range []string{"datetime", "content"} {
+		if value, ok := elem.Attr(attr); ok {
+			if t, ok := parseDate(value); ok {
+				return t, true
+			}
+		}
+	}
+	return parseDate(elem.Text())
+}
+
+// articleDateSelectors are the meta tags that carry an article's publish date
+var articleDateSelectors = []string{
+	"meta[property='article:published_time']",
+	"meta[itemprop='datePublished']",
+	"meta[name='pubdate']",
+	"meta[name='publish-date']",
+	"meta[name='date']",
+}
+
+// dateFromArticle extracts the publish date of an article page from its meta
+// tags, its JSON-LD data or, as a last resort, its first <time datetime>
+func dateFromArticle(doc *goquery.Document) (time.Time, bool) {
+	for _, selector := range articleDateSelectors {
+		if t, ok := dateFromSelection(doc.Selection, selector); ok {
+			return t, true
+		}
+	}
+
+	var found time.Time
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		if value := findJSONKey(data, "datePublished"); value != "" {
+			if t, ok := parseDate(value); ok {
+				found = t
+				return false
+			}
+		}
+		return true
+	})
+	if !found.IsZero() {
+		return found, true
+	}
+
+	return dateFromSelection(doc.Selection, defaultDateSelector)
+}
+
+// findJSONKey walks decoded JSON-LD, including arrays and @graph, for the first string value of key
+func findJSONKey(data any, key string) string {
+	switch v := data.(type) {
+	case map[string]any:
+		if s, ok := v[key].(string); ok {
+			return s
+		}
+		for _, child := range v {
+			if s := findJSONKey(child, key); s != "" {
+				return s
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if s := findJSONKey(child, key); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// fillArticleDates fetches the pages of the items without a date in parallel and reads the dates from them
+func (na *NewsAggregator) fillArticleDates(ctx context.Context, news []NewsItem) {
+	var wg sync.WaitGroup
+	for i := range news {
+		if !news[i].DateUnknown {
+			continue
+		}
+		wg.Add(1)
+		go func(item *NewsItem) {
+			defer wg.Done()
+			doc, err := na.fetchArticle(ctx, item.Link)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error fetching article %s for its date: %v", item.Link, err)
+				}
+				return
+			}
+			if t, ok := dateFromArticle(doc); ok {
+				item.PublishDate = t
+				item.DateUnknown = false
+			}
+		}(&news[i])
+	}
+	wg.Wait()
+}
+
+// dropStale removes items with a known publish date older than maxNewsAge
+func dropStale(news []NewsItem) []NewsItem {
+	var fresh []NewsItem
+	for _, item := range news {
+		if item.DateUnknown || time.Since(item.PublishDate) <= maxNewsAge {
+			fresh = append(fresh, item)
+		}
+	}
+	return fresh
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseDate(t *testing.T) {
+	madrid := time.FixedZone("", 2*60*60)
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2026-10-16T06:30:00Z", time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC), true},
+		{"2026-10-16T06:30:00.123+02:00", time.Date(2026, 10, 16, 6, 30, 0, 123_000_000, madrid), true},
+		{"2026-10-16T06:30:00+0200", time.Date(2026, 10, 16, 6, 30, 0, 0, madrid), true},
+		{"2026-10-16T06:30:00", time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC), true},
+		{"2026-10-16T06:30+02:00", time.Date(2026, 10, 16, 6, 30, 0, 0, madrid), true},
+		{"2026-10-16 06:30:00", time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC), true},
+		{"2026-10-16", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), true},
+		{"Fri, 16 Oct 2026 06:30:00 +0200", time.Date(2026, 10, 16, 6, 30, 0, 0, madrid), true},
+		{"Fri, 16 Oct 2026 06:30:00 GMT", time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC), true},
+		{"  2026-10-16  ", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), true},
+		{"16 de octubre de 2026", time.Time{}, false},
+		{"hace 2 horas", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := parseDate(tt.in)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDateFromArticle(t *testing.T) {
+	want := time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		html string
+		ok   bool
+	}{
+		{"article meta", `<head><meta property="article:published_time" content="2026-10-16T06:30:00Z"></head>`, true},
+		{"itemprop meta", `<head><meta itemprop="datePublished" content="2026-10-16T06:30:00Z"></head>`, true},
+		{"pubdate meta", `<head><meta name="pubdate" content="2026-10-16T06:30:00Z"></head>`, true},
+		{"unparseable meta falls through", `<head><meta name="date" content="ayer"></head><body><time datetime="2026-10-16T06:30:00Z">hoy</time></body>`, true},
+		{"meta before json-ld", `<head><meta name="publish-date" content="2026-10-16T06:30:00Z"><script type="application/ld+json">{"datePublished": "2020-01-01"}</script></head>`, true},
+		{"json-ld", `<script type="application/ld+json">{"@type": "NewsArticle", "datePublished": "2026-10-16T06:30:00Z"}</script>`, true},
+		{"json-ld graph", `<script type="application/ld+json">{"@graph": [{"@type": "WebPage"}, {"@type": "NewsArticle", "datePublished": "2026-10-16T06:30:00Z"}]}</script>`, true},
+		{"json-ld array", `<script type="application/ld+json">[{"@type": "Organization"}, {"datePublished": "2026-10-16T06:30:00Z"}]</script>`, true},
+		{"broken json-ld skipped", `<script type="application/ld+json">{"datePublished": </script><script type="application/ld+json">{"datePublished": "2026-10-16T06:30:00Z"}</script>`, true},
+		{"json-ld before time", `<script type="application/ld+json">{"datePublished": "2026-10-16T06:30:00Z"}</script><time datetime="2020-01-01">antes</time>`, true},
+		{"time element", `<body><time datetime="2026-10-16T06:30:00Z">16 de octubre</time><time datetime="2020-01-01"></time></body>`, true},
+		{"time text", `<body><time datetime="">2026-10-16T06:30:00Z</time></body>`, true},
+		{"no date", `<body><p>Texto del artículo</p><time>ayer</time></body>`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := dateFromArticle(doc)
+			if ok != tt.ok || ok && !got.Equal(want) {
+				t.Errorf("dateFromArticle = %v, %v, want %v, %v", got, ok, want, tt.ok)
+			}
+		})
+	}
+}
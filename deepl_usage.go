@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"unicode/utf8"
+)
+
+// errTranslationBudget is returned when a batch would exceed the character budget or the DeepL quota
+var errTranslationBudget = errors.New("translation character budget exhausted")
+
+// DeepLUsage represents the DeepL /v2/usage response
+type DeepLUsage struct {
+	CharacterCount int64 `json:"character_count"`
+	CharacterLimit int64 `json:"character_limit"`
+}
+
+// partialTranslationError is returned when the budget allowed only the leading
+// texts of a batch. Translations has one entry per text of the batch; where
+// Done is false the text was not sent and the original is kept.
+type partialTranslationError struct {
+	Translations []string
+	Done         []bool
+	Err          error
+}
+
+func (e *partialTranslationError) Error() string {
+	translated := 0
+	for _, done := range e.Done {
+		if done {
+			translated++
+		}
+	}
+	return fmt.Sprintf("%d of %d texts translated: %v", translated, len(e.Done), e.Err)
+}
+
+func (e *partialTranslationError) Unwrap() error { return e.Err }
+
+// TranslationUsage is what a metered translator consumed during a run
+type TranslationUsage struct {
+	CharactersSent    int   `json:"characters_sent"`
+	CharactersRefused int   `json:"characters_refused"`    // Left untranslated to stay within the budget or quota
+	QuotaUsed         int64 `json:"quota_used,omitempty"`  // Account characters used including this run, when the quota was read
+	QuotaLimit        int64 `json:"quota_limit,omitempty"` // Account character limit, when the quota was read
+}
+
+// usageReporter is implemented by translators that meter the characters they send
+type usageReporter interface {
+	LogUsage()
+	RunUsage() TranslationUsage
+}
+
+// Usage queries how many characters of the account's quota are used
+func (t *DeepLTranslator) Usage(ctx context.Context) (DeepLUsage, error) {
+	resp, err := doWithRetry(ctx, t.client, translationRetry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", t.endpoint+"/v2/usage", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "DeepL-Auth-Key "+t.apiKey)
+		return req, nil
+	})
+	if err != nil {
+		return DeepLUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return DeepLUsage{}, fmt.Errorf("DeepL API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var usage DeepLUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return DeepLUsage{}, err
+	}
+	return usage, nil
+}
+
+// reserve checks a batch against the run budget and the remaining account
+// quota and counts the leading texts that fit as sent, returning how many fit
+// and their size. When not all of them fit, err wraps errTranslationBudget.
+// The quota is looked up once, on the first batch.
+func (t *DeepLTranslator) reserve(ctx context.Context, texts []string) (n, chars int, err error) {
+	if !t.usageChecked {
+		t.usageChecked = true
+		usage, err := t.Usage(ctx)
+		if err != nil {
+			log.Printf("Could not check DeepL usage, translating without quota checks: %v", err)
+		} else {
+			t.usage = &usage
+			log.Printf("DeepL usage: %d of %d characters", usage.CharacterCount, usage.CharacterLimit)
+		}
+	}
+
+	// The tighter of the run budget and the quota applies, -1 means neither is set
+	left, limit := int64(-1), ""
+	if t.budget > 0 {
+		left, limit = int64(t.budget-t.charsSent), fmt.Sprintf("of the %d character run budget", t.budget)
+	}
+	if t.usage != nil && t.usage.CharacterLimit > 0 {
+		quotaLeft := max(t.usage.CharacterLimit-t.usage.CharacterCount-int64(t.quotaReserve)-int64(t.charsSent), 0)
+		if left < 0 || quotaLeft < left {
+			left, limit = quotaLeft, "in the DeepL quota"
+		}
+	}
+
+	// Texts are taken in order until one does not fit
+	needed := 0
+	for i, text := range texts {
+		needed += utf8.RuneCountInString(text)
+		if n == i && (left < 0 || int64(needed) <= left) {
+			n, chars = i+1, needed
+		}
+	}
+	if n < len(texts) {
+		err = fmt.Errorf("%w: %d characters needed, %d left %s", errTranslationBudget, needed, left, limit)
+	}
+
+	t.charsSent += chars
+	return n, chars, err
+}
+
+// LogUsage reports the characters sent during this run
+func (t *DeepLTranslator) LogUsage() {
+	usage := t.RunUsage()
+	if usage.CharactersRefused > 0 {
+		log.Printf("DeepL: %d characters left untranslated to stay within the budget", usage.CharactersRefused)
+	}
+	if usage.QuotaLimit > 0 {
+		log.Printf("DeepL: %d characters sent this run, %d of %d used (%.1f%%)",
+			usage.CharactersSent, usage.QuotaUsed, usage.QuotaLimit, 100*float64(usage.QuotaUsed)/float64(usage.QuotaLimit))
+		return
+	}
+	log.Printf("DeepL: %d characters sent this run", usage.CharactersSent)
+}
+
+// RunUsage returns the characters sent and refused during this run
+func (t *DeepLTranslator) RunUsage() TranslationUsage {
+	usage := TranslationUsage{CharactersSent: t.charsSent, CharactersRefused: t.charsRefused}
+	if t.usage != nil && t.usage.CharacterLimit > 0 {
+		usage.QuotaUsed = t.usage.CharacterCount + int64(t.charsSent)
+		usage.QuotaLimit = t.usage.CharacterLimit
+	}
+	return usage
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newFakeDeepL serves /v2/usage with the given quota and /v2/translate by upper-casing the texts
+func newFakeDeepL(t *testing.T, count, limit int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/usage":
+			json.NewEncoder(w).Encode(DeepLUsage{CharacterCount: count, CharacterLimit: limit})
+		case "/v2/translate":
+			var req struct {
+				Text []string `json:"text"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			var resp DeepLTranslation
+			for _, text := range req.Text {
+				resp.Translations = append(resp.Translations, struct {
+					DetectedSourceLanguage string `json:"detected_source_language"`
+					Text                   string `json:"text"`
+				}{Text: strings.ToUpper(text)})
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDeepLTrimsBatchToBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  int
+		count   int64
+		limit   int64
+		reserve int
+		want    []string
+		done    []bool // nil when the whole batch is translated
+		refused int
+	}{
+		{"within budget", 100, 0, 0, 0, []string{"UNO", "DOS", "TRES"}, nil, 0},
+		{"trimmed by budget", 6, 0, 0, 0, []string{"UNO", "DOS", "tres"}, []bool{true, true, false}, 4},
+		{"trimmed by quota", 0, 990, 1000, 3, []string{"UNO", "DOS", "tres"}, []bool{true, true, false}, 4},
+		{"quota already spent", 0, 1000, 1000, 0, nil, []bool{}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeDeepL(t, tt.count, tt.limit)
+			tr := &DeepLTranslator{client: srv.Client(), endpoint: srv.URL, budget: tt.budget, quotaReserve: tt.reserve}
+
+			got, err := tr.Translate(context.Background(), []string{"uno", "dos", "tres"}, "es", "ru")
+			var partial *partialTranslationError
+			switch {
+			case tt.done == nil:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case len(tt.done) == 0:
+				if !errors.Is(err, errTranslationBudget) || errors.As(err, &partial) {
+					t.Fatalf("err = %v, want the budget error without translations", err)
+				}
+			default:
+				if !errors.As(err, &partial) || !errors.Is(err, errTranslationBudget) {
+					t.Fatalf("err = %v, want a partial translation", err)
+				}
+				got = partial.Translations
+				if !slices.Equal(partial.Done, tt.done) {
+					t.Errorf("Done = %v, want %v", partial.Done, tt.done)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("translations = %q, want %q", got, tt.want)
+			}
+			if usage := tr.RunUsage(); usage.CharactersRefused != tt.refused {
+				t.Errorf("CharactersRefused = %d, want %d", usage.CharactersRefused, tt.refused)
+			}
+		})
+	}
+}
+
+func TestTranslateItemsKeepsTrimmedTitles(t *testing.T) {
+	srv := newFakeDeepL(t, 0, 0)
+	config := DefaultConfig()
+	config.MetricsFile = filepath.Join(t.TempDir(), "metrics.json")
+	// Room for both titles but not the description that follows them
+	tr := &DeepLTranslator{client: srv.Client(), endpoint: srv.URL, budget: 9}
+	na := &NewsAggregator{config: config, translator: tr, usage: tr}
+
+	news := []NewsItem{
+		{Title: "hola", Description: "una descripción larga", Language: "es"},
+		{Title: "adiós", Language: "es"},
+	}
+	na.translateItems(context.Background(), news, "ru")
+
+	wantStatus := []TranslationStatus{TranslationPartial, TranslationDone}
+	for i, item := range news {
+		if got := item.TranslationStatus("ru"); got != wantStatus[i] {
+			t.Errorf("item %d status = %s, want %s", i, got, wantStatus[i])
+		}
+	}
+	if got := news[0].TitleIn("ru"); got != "HOLA" {
+		t.Errorf("title = %q, want the translation", got)
+	}
+
+	if err := na.saveRunMetrics(news[0].PublishDate); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(config.MetricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metrics RunMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Translation == nil || metrics.Translation.CharactersSent != 9 || metrics.Translation.CharactersRefused != 21 {
+		t.Errorf("metrics = %s, want 9 characters sent and 21 refused", data)
+	}
+}
+
+func TestTranslateItemsKeepsDetectedLanguage(t *testing.T) {
+	var sourceLangs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/translate" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Text       []string `json:"text"`
+			SourceLang string   `json:"source_lang"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		sourceLangs = append(sourceLangs, req.SourceLang)
+		var resp DeepLTranslation
+		for _, text := range req.Text {
+			resp.Translations = append(resp.Translations, struct {
+				DetectedSourceLanguage string `json:"detected_source_language"`
+				Text                   string `json:"text"`
+			}{DetectedSourceLanguage: "ES", Text: strings.ToUpper(text)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	tr := &DeepLTranslator{client: srv.Client(), endpoint: srv.URL}
+	na := &NewsAggregator{config: DefaultConfig(), translator: tr}
+	news := []NewsItem{
+		{Title: "Real Madrid", Description: "Ancelotti"},
+		{Title: "hello", Language: "en"},
+	}
+	na.TranslateNewsItems(context.Background(), news, []string{"ru", "es"})
+
+	if news[0].Language != "es" {
+		t.Errorf("language = %q, want the detected es", news[0].Language)
+	}
+	if news[1].Language != "en" {
+		t.Errorf("declared language changed to %q", news[1].Language)
+	}
+	if got := news[0].TranslationStatus("es"); got != TranslationOriginal {
+		t.Errorf("status in es = %s, want %s", got, TranslationOriginal)
+	}
+	// ru: one batch without a hint and one from en; es: only the English item
+	if want := []string{"", "EN", "EN"}; !slices.Equal(sourceLangs, want) {
+		t.Errorf("source languages sent = %q, want %q", sourceLangs, want)
+	}
+}
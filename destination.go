@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Destination is one webhook digests are delivered to
+type Destination struct {
+	Name      string            `json:"name"`      // Unique, shown in logs, defaults to the URL's host
+	URL       string            `json:"url"`       // $VAR and ${VAR} are read from the environment
+	Channel   string            `json:"channel"`   // Payload format, defaults to the config's channel
+	Languages []string          `json:"languages"` // Languages to deliver, defaults to the config's languages
+	Regions   []string          `json:"regions"`   // Region codes to deliver, empty means every region
+	Headers   map[string]string `json:"headers"`   // Extra request headers such as Authorization, values read like URL
+
+	SigningSecretEnv string `json:"signing_secret_env"` // Environment variable holding the signing secret, defaults to WEBHOOK_SIGNING_SECRET
+	signingSecret    string // Requests are signed when set
+}
+
+// defaultSigningSecretEnv holds the secret requests are signed with unless a destination names its own
+const defaultSigningSecretEnv = "WEBHOOK_SIGNING_SECRET"
+
+// covers reports whether the destination receives the given region's digest
+func (d Destination) covers(region string) bool {
+	return len(d.Regions) == 0 || slices.Contains(d.Regions, region)
+}
+
+// uniqueName returns name, or name with the first free number from 2 on
+// appended when it is already taken, and marks the result as taken
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// ResolveDestinations returns every destination of the run: the configured
+// ones, one per region with its own webhook_url and, when no destinations are
+// configured, WEBHOOK_URL for the remaining regions. Environment references
+// are expanded, signing secrets read and defaults filled in.
+// Names key the outbox and idempotency keys, so a name given twice is an
+// error and a host used as the name of several destinations is numbered.
+func ResolveDestinations(config Config, regions []RegionProfile) ([]Destination, error) {
+	destinations := slices.Clone(config.Destinations)
+
+	var unrouted []string
+	for _, r := range regions {
+		if r.WebhookURL != "" {
+			destinations = append(destinations, Destination{
+				Name:    "region " + r.Code,
+				URL:     r.WebhookURL,
+				Channel: r.channel(config.Channel),
+				Regions: []string{r.Code},
+			})
+		} else {
+			unrouted = append(unrouted, r.Code)
+		}
+	}
+	if len(config.Destinations) == 0 && config.WebhookURL != "" && len(unrouted) > 0 {
+		destinations = append(destinations, Destination{Name: "default", URL: config.WebhookURL, Regions: unrouted})
+	}
+
+	names := make(map[string]bool, len(destinations))
+	for _, d := range destinations {
+		if d.Name == "" {
+			continue
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("more than one destination is named %q", d.Name)
+		}
+		names[d.Name] = true
+	}
+
+	for i := range destinations {
+		d := &destinations[i]
+		d.URL = os.ExpandEnv(d.URL)
+		if d.URL == "" {
+			return nil, fmt.Errorf("destination %d has no url", i)
+		}
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			return nil, fmt.Errorf("destination %d has an invalid url: %v", i, err)
+		}
+		if d.Name == "" {
+			d.Name = uniqueName(u.Host, names)
+		}
+		if d.Channel == "" {
+			d.Channel = config.Channel
+		}
+		if _, ok := channelMessageLimits[d.Channel]; !ok {
+			return nil, fmt.Errorf("destination %q has unknown channel %q", d.Name, d.Channel)
+		}
+		if len(d.Languages) == 0 {
+			d.Languages = config.Languages
+		}
+		d.Languages = slices.Clone(d.Languages)
+		for j, lang := range d.Languages {
+			d.Languages[j] = strings.ToLower(strings.TrimSpace(lang))
+		}
+		if d.SigningSecretEnv == "" {
+			d.signingSecret = os.Getenv(defaultSigningSecretEnv)
+		} else if d.signingSecret = os.Getenv(d.SigningSecretEnv); d.signingSecret == "" {
+			return nil, fmt.Errorf("destination %q: signing secret variable %s is not set", d.Name, d.SigningSecretEnv)
+		}
+		if len(d.Headers) > 0 {
+			headers := make(map[string]string, len(d.Headers))
+			for k, v := range d.Headers {
+				headers[k] = os.ExpandEnv(v)
+			}
+			d.Headers = headers
+		}
+	}
+
+	for _, r := range regions {
+		if !slices.ContainsFunc(destinations, func(d Destination) bool { return d.covers(r.Code) }) {
+			return nil, fmt.Errorf("region %q has no destination: set its webhook_url, WEBHOOK_URL or a destination", r.Code)
+		}
+	}
+	return destinations, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveDestinationsNames(t *testing.T) {
+	tests := []struct {
+		name         string
+		destinations []Destination
+		want         []string
+		err          string
+	}{
+		{
+			name: "named",
+			destinations: []Destination{
+				{Name: "ru-telegram", URL: "https://api.telegram.org/bot1/sendMessage"},
+				{Name: "en-telegram", URL: "https://api.telegram.org/bot1/sendMessage"},
+			},
+			want: []string{"ru-telegram", "en-telegram"},
+		},
+		{
+			name: "shared host numbered",
+			destinations: []Destination{
+				{URL: "https://hooks.slack.com/services/a"},
+				{URL: "https://hooks.slack.com/services/b"},
+				{URL: "https://hooks.slack.com/services/c"},
+			},
+			want: []string{"hooks.slack.com", "hooks.slack.com-2", "hooks.slack.com-3"},
+		},
+		{
+			name: "host numbered past a given name",
+			destinations: []Destination{
+				{URL: "https://hooks.slack.com/services/a"},
+				{Name: "hooks.slack.com", URL: "https://hooks.slack.com/services/b"},
+			},
+			want: []string{"hooks.slack.com-2", "hooks.slack.com"},
+		},
+		{
+			name: "name given twice",
+			destinations: []Destination{
+				{Name: "news", URL: "https://example.com/a"},
+				{Name: "news", URL: "https://example.com/b"},
+			},
+			err: `more than one destination is named "news"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Destinations = tt.destinations
+			destinations, err := ResolveDestinations(config, []RegionProfile{{Code: "es"}})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, d := range destinations {
+				names = append(names, d.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("names = %q, want %q", names, tt.want)
+			}
+			if idempotencyKey(destinations[0], []byte("body")) == idempotencyKey(destinations[1], []byte("body")) {
+				t.Error("two destinations share an idempotency key")
+			}
+		})
+	}
+}
+
+func TestRegionLanguages(t *testing.T) {
+	config := DefaultConfig()
+	config.Languages = []string{"ru"}
+	config.Destinations = []Destination{
+		{Name: "es-ru", URL: "https://example.com/a", Regions: []string{"es"}},
+		{Name: "es-en", URL: "https://example.com/b", Regions: []string{"es"}, Languages: []string{" EN ", "ru"}},
+		{Name: "mx-uk", URL: "https://example.com/c", Regions: []string{"mx"}, Languages: []string{"uk"}},
+	}
+	destinations, err := ResolveDestinations(config, []RegionProfile{{Code: "es"}, {Code: "mx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Destinations = destinations
+	na := &NewsAggregator{config: config}
+
+	tests := []struct {
+		region string
+		want   []string
+	}{
+		{"es", []string{"ru", "en"}},
+		{"mx", []string{"uk"}},
+	}
+	for _, tt := range tests {
+		if got := na.regionLanguages(tt.region); !slices.Equal(got, tt.want) {
+			t.Errorf("regionLanguages(%s) = %q, want %q", tt.region, got, tt.want)
+		}
+	}
+	if !slices.Equal(config.Languages, []string{"ru"}) {
+		t.Errorf("config languages = %q, destinations must not add to them", config.Languages)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fetchResult is what a worker reports back for one source
+type fetchResult struct {
+	index  int
+	result SourceResult
+	err    error
+}
+
+// fetchSources fetches every registered source with a bounded worker pool.
+// Each source gets its own deadline, and once the run deadline passes the
+// results that already arrived are returned, keyed by source name, without
+// waiting for the rest.
+func (na *NewsAggregator) fetchSources(ctx context.Context) map[string]SourceResult {
+	ctx, cancel := context.WithTimeout(ctx, na.config.RunTimeout.Duration)
+	defer cancel()
+
+	sources := na.sources.All()
+	jobs := make(chan int)
+	results := make(chan fetchResult, len(sources)) // Buffered so late workers never block
+
+	workers := min(na.config.MaxConcurrentFetches, len(sources))
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results <- na.fetchSource(ctx, i, sources[i])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range sources {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	collected := make(map[string]SourceResult)
+collect:
+	for received := 0; received < len(sources); received++ {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				log.Printf("Error fetching %s: %v", sources[r.index].Name(), r.err)
+				continue
+			}
+			collected[sources[r.index].Name()] = r.result
+		case <-ctx.Done():
+			log.Printf("Run deadline reached after %d of %d sources, using partial results", received, len(sources))
+			break collect
+		}
+	}
+
+	return collected
+}
+
+// fetchSource fetches a single source under its own deadline
+func (na *NewsAggregator) fetchSource(ctx context.Context, index int, src Source) fetchResult {
+	ctx, cancel := context.WithTimeout(ctx, na.config.SourceTimeout.Duration)
+	defer cancel()
+
+	start := time.Now()
+	result, err := src.Fetch(ctx)
+	if err == nil {
+		log.Printf("Fetched %s in %s (%d news, %d trends)",
+			src.Name(), time.Since(start).Round(time.Millisecond), len(result.News), len(result.Trends))
+	}
+	return fetchResult{index: index, result: result, err: err}
+}
+
+// articlePages fetches the article pages read for publish dates and full text.
+// Downloads share max_concurrent_fetches slots, and each page is kept for the
+// rest of the run, keyed by its normalized link, so an article is downloaded
+// once however many sources and regions need it.
+type articlePages struct {
+	ctx   context.Context // The run's context, downloads outlive the caller that started them
+	slots chan struct{}
+	mu    sync.Mutex
+	pages map[string]*articlePage
+}
+
+// articlePage is a downloaded page, or one still downloading while done is open
+type articlePage struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// newArticlePages returns an empty page cache for the run with the given
+// context, downloading at most workers pages at a time
+func newArticlePages(ctx context.Context, workers int) *articlePages {
+	return &articlePages{ctx: ctx, slots: make(chan struct{}, max(workers, 1)), pages: make(map[string]*articlePage)}
+}
+
+// fetchArticle returns the parsed article page at link, downloading it unless
+// an earlier call in the run already did. Callers waiting on the same page
+// share one download, which runs under the run's context so that a caller
+// giving up at its own deadline leaves it going for the others; a failed
+// download is forgotten so later callers can try again.
+func (na *NewsAggregator) fetchArticle(ctx context.Context, link string) (*goquery.Document, error) {
+	p := na.articles
+	key := normalizeLink(link)
+
+	p.mu.Lock()
+	page, ok := p.pages[key]
+	if !ok {
+		page = &articlePage{done: make(chan struct{})}
+		p.pages[key] = page
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		go na.downloadArticle(p, page, key, link)
+	}
+
+	select {
+	case <-page.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if page.err != nil {
+		return nil, page.err
+	}
+	// Parsed anew on every call, as readers may modify the document
+	return goquery.NewDocumentFromReader(bytes.NewReader(page.body))
+}
+
+// downloadArticle fills in page once a download slot is free and closes its done channel
+func (na *NewsAggregator) downloadArticle(p *articlePages, page *articlePage, key, link string) {
+	select {
+	case p.slots <- struct{}{}:
+		page.body, page.err = na.fetchPage(p.ctx, link)
+		<-p.slots
+	case <-p.ctx.Done():
+		page.err = p.ctx.Err()
+	}
+	if page.err != nil {
+		p.mu.Lock()
+		delete(p.pages, key)
+		p.mu.Unlock()
+	}
+	close(page.done)
+}
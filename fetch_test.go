@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchArticleDownloadsOncePerRun(t *testing.T) {
+	var hits atomic.Int32
+	fail := atomic.Bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<html><body><p>Texto del artículo</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	na := &NewsAggregator{config: DefaultConfig(), client: srv.Client(), articles: newArticlePages(context.Background(), 2)}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, link := range []string{srv.URL + "/a", srv.URL + "/a?utm_source=rss", srv.URL + "/a/", srv.URL + "/a#top"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := na.fetchArticle(ctx, link)
+			if err != nil {
+				t.Errorf("fetchArticle(%s): %v", link, err)
+				return
+			}
+			if got := doc.Find("p").Text(); got != "Texto del artículo" {
+				t.Errorf("fetchArticle(%s) text = %q", link, got)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("the same article was downloaded %d times, want 1", n)
+	}
+
+	// A failed download is not kept, so the next caller tries again
+	fail.Store(true)
+	if _, err := na.fetchArticle(ctx, srv.URL+"/b"); err == nil {
+		t.Fatal("fetchArticle succeeded on a 503")
+	}
+	fail.Store(false)
+	if _, err := na.fetchArticle(ctx, srv.URL+"/b"); err != nil {
+		t.Errorf("fetchArticle after a failure: %v", err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("got %d downloads, want 3", n)
+	}
+}
+
+func TestFetchArticleOutlivesTheFirstCaller(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`<html><body><p>Texto del artículo</p></body></html>`))
+	}))
+	defer srv.Close()
+
+	na := &NewsAggregator{config: DefaultConfig(), client: srv.Client(), articles: newArticlePages(context.Background(), 2)}
+
+	// The first source gives up before the page arrives
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := na.fetchArticle(short, srv.URL+"/a")
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// A second source waiting on the same page still gets it
+	doc, err := na.fetchArticle(context.Background(), srv.URL+"/a")
+	if err != nil {
+		t.Fatalf("fetchArticle for the second source: %v", err)
+	}
+	if got := doc.Find("p").Text(); got != "Texto del artículo" {
+		t.Errorf("text = %q", got)
+	}
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Errorf("first source err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("got %d downloads, want 1", n)
+	}
+}
+
+// stubSource returns its result after delay, or the context error if that comes first
+type stubSource struct {
+	name         string
+	delay        time.Duration
+	ignoreCancel bool // Keep going after the context is done, like a stuck request
+}
+
+func (s stubSource) Name() string     { return s.name }
+func (s stubSource) Kind() SourceKind { return SourceKindNews }
+
+func (s stubSource) Fetch(ctx context.Context) (SourceResult, error) {
+	result := SourceResult{News: []NewsItem{{Title: s.name}}}
+	if s.ignoreCancel {
+		time.Sleep(s.delay)
+		return result, nil
+	}
+	select {
+	case <-time.After(s.delay):
+		return result, nil
+	case <-ctx.Done():
+		return SourceResult{}, ctx.Err()
+	}
+}
+
+func TestFetchSourcesDeadlines(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []stubSource
+		want    []string
+		maxWait time.Duration
+	}{
+		{"all in time", []stubSource{{name: "a"}, {name: "b", delay: 10 * time.Millisecond}}, []string{"a", "b"}, time.Second},
+		{"source deadline", []stubSource{{name: "a"}, {name: "slow", delay: time.Minute}}, []string{"a"}, time.Second},
+		{"run deadline", []stubSource{{name: "a"}, {name: "stuck", delay: 2 * time.Second, ignoreCancel: true}}, []string{"a"}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.SourceTimeout = Duration{100 * time.Millisecond}
+			config.RunTimeout = Duration{300 * time.Millisecond}
+			na := &NewsAggregator{config: config, sources: NewSourceRegistry()}
+			for _, src := range tt.sources {
+				na.RegisterSource(src)
+			}
+
+			start := time.Now()
+			results := na.fetchSources(context.Background())
+			if elapsed := time.Since(start); elapsed > tt.maxWait {
+				t.Errorf("fetchSources took %s", elapsed)
+			}
+			var got []string
+			for name := range results {
+				got = append(got, name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results from %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// FilterPolicy decides what happens to a source's items that mention none of the Spain keywords
+type FilterPolicy string
+
+const (
+	// FilterRequireMatch drops items that do not mention a keyword
+	FilterRequireMatch FilterPolicy = "require_match"
+	// FilterScoreOnly keeps every item but demotes the ones that do not mention a keyword
+	FilterScoreOnly FilterPolicy = "score_only"
+	// FilterPassThrough keeps every item as is, for outlets that only cover Spain
+	FilterPassThrough FilterPolicy = "pass_through"
+)
+
+// defaultFilterPolicy applies to sources that do not set one
+const defaultFilterPolicy = FilterScoreOnly
+
+// validate checks that the policy is one of the known values
+func (p FilterPolicy) validate() error {
+	switch p {
+	case "", FilterRequireMatch, FilterScoreOnly, FilterPassThrough:
+		return nil
+	}
+	return fmt.Errorf("unknown filter policy %q", p)
+}
+
+// filteredSource is implemented by sources that choose their own filter policy
+type filteredSource interface {
+	FilterPolicy() FilterPolicy
+}
+
+// filterPolicies maps each registered news source to its filter policy
+func (na *NewsAggregator) filterPolicies() map[string]FilterPolicy {
+	policies := make(map[string]FilterPolicy)
+	for _, s := range na.sources.Sources(SourceKindNews) {
+		policy := defaultFilterPolicy
+		if fs, ok := s.(filteredSource); ok && fs.FilterPolicy() != "" {
+			policy = fs.FilterPolicy()
+		}
+		policies[s.Name()] = policy
+	}
+	return policies
+}
+
+// applyFilterPolicies filters every item by the policy of the source it came from,
+// matching against the keywords of the region being built
+func (na *NewsAggregator) applyFilterPolicies(news []NewsItem, keywords []string) []NewsItem {
+	policies := na.filterPolicies()
+
+	var filtered []NewsItem
+	dropped := make(map[string]int)
+	for _, item := range news {
+		policy, ok := policies[item.Source]
+		if !ok {
+			policy = defaultFilterPolicy
+		}
+
+		matched := matchesKeywords(item, keywords)
+		switch policy {
+		case FilterRequireMatch:
+			if !matched {
+				dropped[item.Source]++
+				continue
+			}
+		case FilterScoreOnly:
+			item.Unmatched = !matched
+		}
+		filtered = append(filtered, item)
+	}
+
+	for source, n := range dropped {
+		log.Printf("Filtered out %d %s items matching no region keyword", n, source)
+	}
+	return filtered
+}
+
+// matchesKeywords reports whether the item's title, description or full text mentions any keyword
+func matchesKeywords(item NewsItem, keywords []string) bool {
+	content := strings.ToLower(item.Title + " " + item.Description + " " + item.FullText)
+	for _, keyword := range keywords {
+		if strings.Contains(content, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestApplyFilterPolicies(t *testing.T) {
+	na := &NewsAggregator{sources: NewSourceRegistry()}
+	na.RegisterCatalog([]SourceSpec{
+		{Name: "BBC Mundo", Enabled: true, Filter: FilterRequireMatch},
+		{Name: "Reuters", Enabled: true, Filter: FilterScoreOnly},
+		{Name: "El País", Enabled: true, Filter: FilterPassThrough},
+		{Name: "Europa Press", Enabled: true},
+	})
+	na.sources.Register(stubSource{name: "Stub"})
+	keywords := normalizeKeywords([]string{"España", "Madrid"})
+
+	tests := []struct {
+		name      string
+		item      NewsItem
+		kept      bool
+		unmatched bool
+	}{
+		{"require match, title matches", NewsItem{Title: "Madrid acoge la cumbre", Source: "BBC Mundo"}, true, false},
+		{"require match, description matches", NewsItem{Title: "Cumbre", Description: "Se celebra en ESPAÑA", Source: "BBC Mundo"}, true, false},
+		{"require match, full text matches", NewsItem{Title: "Cumbre", FullText: "El encuentro en Madrid", Source: "BBC Mundo"}, true, false},
+		{"require match, no match", NewsItem{Title: "Elecciones en Francia", Source: "BBC Mundo"}, false, false},
+		{"score only, match", NewsItem{Title: "Madrid acoge la cumbre", Source: "Reuters"}, true, false},
+		{"score only, no match", NewsItem{Title: "Elecciones en Francia", Source: "Reuters"}, true, true},
+		{"pass through, no match", NewsItem{Title: "Elecciones en Francia", Source: "El País"}, true, false},
+		{"catalog default is score only", NewsItem{Title: "Elecciones en Francia", Source: "Europa Press"}, true, true},
+		{"source without a policy", NewsItem{Title: "Elecciones en Francia", Source: "Stub"}, true, true},
+		{"unregistered source", NewsItem{Title: "Elecciones en Francia", Source: "Desconocido"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := na.applyFilterPolicies([]NewsItem{tt.item}, keywords)
+			if kept := len(got) == 1; kept != tt.kept {
+				t.Fatalf("kept = %v, want %v", kept, tt.kept)
+			}
+			if tt.kept && got[0].Unmatched != tt.unmatched {
+				t.Errorf("Unmatched = %v, want %v", got[0].Unmatched, tt.unmatched)
+			}
+		})
+	}
+
+	// Items keep their order and only the dropped ones go
+	news := []NewsItem{
+		{Title: "Francia", Source: "BBC Mundo"},
+		{Title: "Madrid", Source: "BBC Mundo"},
+		{Title: "Francia", Source: "El País"},
+	}
+	got := na.applyFilterPolicies(news, keywords)
+	if len(got) != 2 || got[0].Title != "Madrid" || got[1].Source != "El País" {
+		t.Errorf("applyFilterPolicies = %+v", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// FullTextConfig controls reading the article pages of the leading stories
+type FullTextConfig struct {
+	Enabled    bool `json:"enabled"`
+	Candidates int  `json:"candidates"`  // Highest scored items whose article page is fetched
+	MinLength  int  `json:"min_length"`  // Extracted text shorter than this many characters is discarded
+	MaxLength  int  `json:"max_length"`  // Extracted text is cut to this many characters
+	SummaryLen int  `json:"summary_len"` // Length of a description built from the text
+}
+
+// weakDescriptionLen is the length below which a feed description is replaced by a summary of the article
+const weakDescriptionLen = 80
+
+var (
+	// unlikelyContent matches the class or id of page furniture that never holds the article
+	unlikelyContent = regexp.MustCompile(`(?i)comment|share|social|related|sidebar|footer|header|menu|nav|promo|banner|advert|newsletter|subscri|cookie|modal|popup|byline|author|caption|breadcrumb`)
+	// likelyContent matches the class or id of article containers
+	likelyContent = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text|cuerpo|noticia`)
+)
+
+// extractFullTexts fetches the article pages of the top candidates and stores
+// their main text. Items whose description is missing or too short to be
+// useful get a summary of the text instead, and items that only mention a
+// keyword in the article body are no longer treated as unmatched.
+func (na *NewsAggregator) extractFullTexts(ctx context.Context, news []NewsItem, keywords []string) {
+	cfg := na.config.FullText
+
+	// Pick the candidates by score, leaving the items in place
+	order := make([]int, len(news))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return news[order[a]].Score > news[order[b]].Score })
+	if len(order) > cfg.Candidates {
+		order = order[:cfg.Candidates]
+	}
+
+	// Pages are shared with the date lookup and the other regions through the run's page cache
+	var wg sync.WaitGroup
+	for _, i := range order {
+		if news[i].FullText != "" || news[i].Link == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(item *NewsItem) {
+			defer wg.Done()
+			doc, err := na.fetchArticle(ctx, item.Link)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error fetching article %s for its text: %v", item.Link, err)
+				}
+				return
+			}
+			text := extractArticleText(doc)
+			if messageLength(text) < cfg.MinLength {
+				return
+			}
+			item.FullText = truncateString(text, cfg.MaxLength)
+		}(&news[i])
+	}
+	wg.Wait()
+
+	extracted := 0
+	for _, i := range order {
+		item := &news[i]
+		if item.FullText == "" {
+			continue
+		}
+		extracted++
+		if messageLength(item.Description) < weakDescriptionLen {
+			item.Description = summarize(item.FullText, cfg.SummaryLen)
+		}
+		if item.Language == "" {
+			item.Language = detectLanguage(item.FullText)
+		}
+		if item.Unmatched && matchesKeywords(*item, keywords) {
+			item.Unmatched = false
+		}
+	}
+	log.Printf("Extracted the full text of %d of %d articles", extracted, len(order))
+}
+
+// extractArticleText returns the main body text of an article page. The
+// JSON-LD articleBody is used when present; otherwise paragraphs are scored
+// readability-style and the text of the best scoring container is returned.
+func extractArticleText(doc *goquery.Document) string {
+	var body string
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		body = cleanText(findJSONKey(data, "articleBody"))
+		return body == ""
+	})
+	if body != "" {
+		return body
+	}
+
+	doc.Find("script, style, noscript, iframe, form, button, nav, header, footer, aside, figure").Remove()
+	doc.Find("[class], [id]").Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "article" {
+			return
+		}
+		attrs := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyContent.MatchString(attrs) && !likelyContent.MatchString(attrs) {
+			s.Remove()
+		}
+	})
+
+	// Every paragraph credits its parent fully and its grandparent by half
+	scores := make(map[*html.Node]float64)
+	doc.Find("p").Each(func(i int, p *goquery.Selection) {
+		text := cleanText(p.Text())
+		length := messageLength(text)
+		if length < 25 || linkDensity(p, length) > 0.5 {
+			return
+		}
+		points := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+
+		parent := p.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		addContentScore(scores, parent, points)
+		if grandparent := parent.Parent(); grandparent.Length() > 0 {
+			addContentScore(scores, grandparent, points/2)
+		}
+	})
+
+	// Walk the candidates in document order so a tie goes to the first one on the page
+	var best *html.Node
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		node := s.Get(0)
+		if score, ok := scores[node]; ok && (best == nil || score > scores[best]) {
+			best = node
+		}
+	})
+	if best == nil {
+		return ""
+	}
+
+	var paragraphs []string
+	goquery.NewDocumentFromNode(best).Find("p").Each(func(i int, p *goquery.Selection) {
+		text := cleanText(p.Text())
+		length := messageLength(text)
+		if length >= 25 && linkDensity(p, length) <= 0.5 {
+			paragraphs = append(paragraphs, text)
+		}
+	})
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// addContentScore adds points to a candidate container, seeding it with a
+// bonus or penalty from its class and id the first time it is seen
+func addContentScore(scores map[*html.Node]float64, s *goquery.Selection, points float64) {
+	node := s.Get(0)
+	if _, seen := scores[node]; !seen {
+		attrs := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		switch {
+		case goquery.NodeName(s) == "article" || likelyContent.MatchString(attrs):
+			scores[node] = 25
+		case unlikelyContent.MatchString(attrs):
+			scores[node] = -25
+		default:
+			scores[node] = 0
+		}
+	}
+	scores[node] += points
+}
+
+// linkDensity is the share of an element's text that sits inside links
+func linkDensity(s *goquery.Selection, length int) float64 {
+	if length == 0 {
+		return 0
+	}
+	linked := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linked += messageLength(cleanText(a.Text()))
+	})
+	return float64(linked) / float64(length)
+}
+
+// summarize returns the leading sentences of text that fit in maxLen characters,
+// falling back to a cut at a word when the first sentence alone is longer
+func summarize(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if messageLength(text) <= maxLen {
+		return text
+	}
+
+	runes := []rune(text)[:maxLen]
+	for i := len(runes) - 1; i > 0; i-- {
+		if strings.ContainsRune(".!?", runes[i]) && (i+1 == len(runes) || runes[i+1] == ' ') {
+			return string(runes[:i+1])
+		}
+	}
+	return truncateString(text, maxLen)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractArticleText(t *testing.T) {
+	const paragraph = "<p>El Gobierno aprobó este martes, tras meses de negociación, los presupuestos generales.</p>"
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"json-ld article body",
+			`<script type="application/ld+json">{"@type": "NewsArticle", "articleBody": "Texto &amp; más"}</script><article>` + paragraph + `</article>`,
+			"Texto & más",
+		},
+		{
+			"article over furniture",
+			`<div class="sidebar">` + paragraph + paragraph + `</div><article><div>` + paragraph + `</div></article>`,
+			"El Gobierno aprobó este martes, tras meses de negociación, los presupuestos generales.",
+		},
+		{
+			"tie goes to the first container",
+			`<body><div><section><p>Noticia uno: el Gobierno aprobó, tras meses de negociación, los presupuestos.</p></section></div>` +
+				`<div><section><p>Noticia dos: el Gobierno aprobó, tras meses de negociación, los presupuestos.</p></section></div></body>`,
+			"Noticia uno: el Gobierno aprobó, tras meses de negociación, los presupuestos.",
+		},
+		{
+			"short and link-heavy paragraphs skipped",
+			`<div><p>Corto.</p><p><a href="/a">Un enlace bastante largo a otra noticia del día</a></p></div>`,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run several times, a pick that depends on map order would vary
+			for range 20 {
+				doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := extractArticleText(doc); got != tt.want {
+					t.Fatalf("extractArticleText = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryEntry records a news item that was delivered to a region's webhook
+type HistoryEntry struct {
+	Region string    `json:"region"`
+	Key    string    `json:"key"`
+	Title  string    `json:"title"`
+	Link   string    `json:"link"`
+	Source string    `json:"source"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// History is the persistent "already sent" store, kept as a JSON file
+type History struct {
+	path    string
+	entries map[string]HistoryEntry
+}
+
+// OpenHistory loads the delivery history from path. A missing file is an empty history.
+func OpenHistory(path string) (*History, error) {
+	h := &History{path: path, entries: make(map[string]HistoryEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing history: %v", err)
+	}
+	for _, e := range entries {
+		h.entries[historyKey(e.Region, e.Key)] = e
+	}
+	return h, nil
+}
+
+// WasSent reports whether the item was delivered to the region within the given window
+func (h *History) WasSent(region string, item NewsItem, window time.Duration, now time.Time) bool {
+	e, ok := h.entries[historyKey(region, normalizeLink(item.Link))]
+	return ok && now.Sub(e.SentAt) < window
+}
+
+// Record marks the items as delivered to the region at the given time, along
+// with the reports merged into them, so the same story from another outlet is
+// not sent again either
+func (h *History) Record(region string, items []NewsItem, at time.Time) {
+	for _, item := range items {
+		h.record(region, StoryReport{Title: item.Title, Link: item.Link, Source: item.Source}, at)
+		for _, report := range item.MergedReports {
+			h.record(region, report, at)
+		}
+	}
+}
+
+// record marks one report as delivered
+func (h *History) record(region string, report StoryReport, at time.Time) {
+	key := normalizeLink(report.Link)
+	if key == "" {
+		return
+	}
+	h.entries[historyKey(region, key)] = HistoryEntry{
+		Region: region,
+		Key:    key,
+		Title:  report.Title,
+		Link:   report.Link,
+		Source: report.Source,
+		SentAt: at,
+	}
+}
+
+// Prune drops entries delivered before the cutoff and returns how many were removed
+func (h *History) Prune(cutoff time.Time) int {
+	removed := 0
+	for key, e := range h.entries {
+		if e.SentAt.Before(cutoff) {
+			delete(h.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Entries returns every entry, most recently sent first
+func (h *History) Entries() []HistoryEntry {
+	entries := make([]HistoryEntry, 0, len(h.entries))
+	for _, e := range h.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SentAt.After(entries[j].SentAt)
+	})
+	return entries
+}
+
+// Save writes the history back to disk, replacing the file atomically
+func (h *History) Save() error {
+	data, err := json.MarshalIndent(h.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("error saving history: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving history: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving history: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("error saving history: %v", err)
+	}
+	return nil
+}
+
+// historyKey scopes a link key to a region, so a story sent to one region's digest can still appear in another's
+func historyKey(region, key string) string {
+	return region + " " + key
+}
+
+// normalizeLink builds the history key for a link so that the same story is
+// recognized regardless of scheme, "www." prefix, tracking parameters or fragment
+func normalizeLink(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(link)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	query := u.Query()
+	for param := range query {
+		if strings.HasPrefix(param, "utm_") || param == "ref" || param == "fbclid" || param == "gclid" {
+			query.Del(param)
+		}
+	}
+
+	key := host + path
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"plain", "https://elpais.com/espana/noticia.html", "elpais.com/espana/noticia.html"},
+		{"scheme and www", "http://www.elpais.com/espana/noticia.html", "elpais.com/espana/noticia.html"},
+		{"host case", "https://ElPais.COM/espana/noticia.html", "elpais.com/espana/noticia.html"},
+		{"trailing slash", "https://elpais.com/espana/noticia/", "elpais.com/espana/noticia"},
+		{"fragment", "https://elpais.com/espana/noticia.html#comentarios", "elpais.com/espana/noticia.html"},
+		{"tracking parameters", "https://elpais.com/a?utm_source=rss&utm_medium=feed&ref=home&fbclid=x&gclid=y", "elpais.com/a"},
+		{"kept parameters", "https://elpais.com/a?id=42&utm_source=rss", "elpais.com/a?id=42"},
+		{"parameter order", "https://elpais.com/a?b=2&a=1", "elpais.com/a?a=1&b=2"},
+		{"surrounding space", "  https://elpais.com/a  ", "elpais.com/a"},
+		{"no host", "Noticia/Sin-Enlace", "noticia/sin-enlace"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeLink(tt.link); got != tt.want {
+				t.Errorf("normalizeLink(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryWasSent(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	h, err := OpenHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Record("es", []NewsItem{{Title: "Noticia", Link: "https://www.elpais.com/a?utm_source=rss"}}, now.Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		region string
+		link   string
+		window time.Duration
+		want   bool
+	}{
+		{"same link", "es", "https://www.elpais.com/a?utm_source=rss", 72 * time.Hour, true},
+		{"normalized link", "es", "http://elpais.com/a/", 72 * time.Hour, true},
+		{"other region", "mx", "https://elpais.com/a", 72 * time.Hour, false},
+		{"outside window", "es", "https://elpais.com/a", 30 * time.Minute, false},
+		{"other link", "es", "https://elpais.com/b", 72 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.WasSent(tt.region, NewsItem{Link: tt.link}, tt.window, now); got != tt.want {
+				t.Errorf("WasSent(%q, %q) = %v, want %v", tt.region, tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistorySaveAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Record("es", []NewsItem{{Link: "https://elpais.com/a"}, {Link: ""}}, now)
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reopened.Entries()); n != 1 {
+		t.Fatalf("got %d entries, want 1: items without a link are not recorded", n)
+	}
+	if !reopened.WasSent("es", NewsItem{Link: "https://elpais.com/a"}, time.Hour, now) {
+		t.Error("reopened history lost the delivered item")
+	}
+	if removed := reopened.Prune(now.Add(time.Minute)); removed != 1 {
+		t.Errorf("Prune removed %d entries, want 1", removed)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageStopwords are frequent function words used to guess a text's language
+var languageStopwords = map[string][]string{
+	"es": {"el", "la", "los", "las", "de", "del", "que", "y", "en", "un", "una", "por", "con", "para", "se", "su", "al", "es", "lo", "como", "más", "pero", "sus", "fue", "este", "esta", "tras", "sobre"},
+	"en": {"the", "of", "and", "to", "in", "a", "is", "that", "for", "on", "with", "as", "was", "by", "at", "from", "his", "her", "it", "are", "be", "has", "have", "after", "over", "says"},
+	"pt": {"o", "a", "os", "as", "do", "da", "dos", "das", "que", "e", "em", "um", "uma", "por", "com", "para", "se", "no", "na", "não", "mais", "foi", "ao", "pelo", "pela"},
+	"fr": {"le", "la", "les", "de", "des", "du", "et", "en", "un", "une", "pour", "dans", "que", "qui", "sur", "au", "aux", "est", "par", "pas", "avec", "il", "elle", "ce"},
+}
+
+// stopwordIndex maps each stopword to the languages it belongs to
+var stopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range languageStopwords {
+		for _, w := range words {
+			index[w] = append(index[w], lang)
+		}
+	}
+	return index
+}()
+
+// detectLanguage guesses the language of a text by counting stopwords.
+// It returns "" when the text is too short or ambiguous to tell.
+func detectLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	counts := make(map[string]int)
+	for _, w := range words {
+		for _, lang := range stopwordIndex[w] {
+			counts[lang]++
+		}
+	}
+
+	best, bestCount, runnerUp := "", 0, 0
+	for lang, n := range counts {
+		if n > bestCount {
+			best, bestCount, runnerUp = lang, n, bestCount
+		} else if n > runnerUp {
+			runnerUp = n
+		}
+	}
+
+	// Require a couple of hits and a clear lead over the next language
+	if bestCount < 2 || bestCount == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// normalizeLangCode reduces a language tag such as "es-ES" or "en_US" to its ISO 639-1 code
+func normalizeLangCode(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) != 2 {
+		return ""
+	}
+	return tag
+}
+
+// detectItemLanguages fills in the language of items whose source did not declare one
+func detectItemLanguages(news []NewsItem) {
+	for i := range news {
+		if news[i].Language == "" {
+			news[i].Language = detectLanguage(news[i].Title + " " + news[i].Description)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"spanish", "El Gobierno aprueba los presupuestos para el año que viene", "es"},
+		{"english", "The government approves the budget for the next year", "en"},
+		{"portuguese", "O governo aprova o orçamento para o próximo ano e não muda nada", "pt"},
+		{"french", "Le gouvernement approuve le budget pour les prochaines années", "fr"},
+		{"case and punctuation", "¡EL GOBIERNO, DE NUEVO, CON LOS PRESUPUESTOS!", "es"},
+		{"one stopword", "Presupuestos del Estado", ""},
+		{"no stopwords", "Real Madrid", ""},
+		{"tie", "la que", ""},
+		{"empty", "", ""},
+		{"cyrillic", "Правительство Испании одобрило бюджет", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.text); got != tt.want {
+				t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectItemLanguages(t *testing.T) {
+	news := []NewsItem{
+		{Title: "El Gobierno aprueba los presupuestos", Language: "en"},
+		{Title: "The government approves the budget"},
+		{Title: "Real Madrid"},
+	}
+	detectItemLanguages(news)
+	for i, want := range []string{"en", "en", ""} {
+		if news[i].Language != want {
+			t.Errorf("item %d language = %q, want %q", i, news[i].Language, want)
+		}
+	}
+}
@@ -2,864 +2,327 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/GLobyNew/SpainHotNewsCrawler/webhooksig"
 	"github.com/joho/godotenv"
 	"github.com/mmcdole/gofeed"
 )
 
 // NewsItem represents a single news item
 type NewsItem struct {
-	Title         string    `json:"title"`
-	TitleRU       string    `json:"title_ru"`
-	Description   string    `json:"description"`
-	DescriptionRU string    `json:"description_ru"`
-	Link          string    `json:"link"`
-	Source        string    `json:"source"`
-	PublishDate   time.Time `json:"publish_date"`
-	Score         int       `json:"score"` // Relevance score for ranking
+	Title          string                 `json:"title"`
+	Description    string                 `json:"description"`
+	FullText       string                 `json:"full_text,omitempty"` // Main text of the article page, when full text extraction is on
+	Link           string                 `json:"link"`
+	Source         string                 `json:"source"`
+	Language       string                 `json:"language,omitempty"`     // ISO 639-1 code of the original text, empty when unknown
+	Translations   map[string]Translation `json:"translations,omitempty"` // Keyed by target language code
+	PublishDate    time.Time              `json:"publish_date"`
+	DateUnknown    bool                   `json:"date_unknown,omitempty"`    // The source gave no publish date, PublishDate is unset
+	Unmatched      bool                   `json:"unmatched,omitempty"`       // Mentions no Spain keyword, kept by a score_only source
+	Score          int                    `json:"score"`                     // Relevance score for ranking
+	AlsoCoveredBy  []string               `json:"also_covered_by,omitempty"` // Other outlets that reported the same story
+	MergedReports  []StoryReport          `json:"merged_reports,omitempty"`  // The other reports of the story, recorded in the history with it
+	ScoreBreakdown []SignalScore          `json:"score_breakdown,omitempty"` // Points per scoring signal
+	RelatedTrends  []string               `json:"related_trends,omitempty"`  // Trending topics the item mentions
 }
 
 // Config holds the application configuration
 type Config struct {
-	WebhookURL     string
-	DeepLAPIKey    string
-	MaxNewsItems   int
-	RequestTimeout time.Duration
-	UserAgent      string
-}
-
-// DeepLTranslation represents the DeepL API response
-type DeepLTranslation struct {
-	Translations []struct {
-		DetectedSourceLanguage string `json:"detected_source_language"`
-		Text                   string `json:"text"`
-	} `json:"translations"`
+	WebhookURL            string            `json:"webhook_url"`
+	DeepLAPIKey           string            `json:"-"`
+	LibreTranslateAPIKey  string            `json:"-"`
+	MaxNewsItems          int               `json:"max_news_items"`
+	RequestTimeout        Duration          `json:"request_timeout"`
+	UserAgent             string            `json:"user_agent"`
+	MaxConcurrentFetches  int               `json:"max_concurrent_fetches"`
+	SourceTimeout         Duration          `json:"source_timeout"`     // Deadline for a single source, covering all of its requests
+	RunTimeout            Duration          `json:"run_timeout"`        // Deadline for fetching all sources, partial results are used after it
+	HistoryFile           string            `json:"history_file"`       // Delivery history used to skip already sent items, empty disables it
+	HistoryWindow         Duration          `json:"history_window"`     // Items delivered within this window are not sent again
+	ClusterSimilarity     float64           `json:"cluster_similarity"` // Headline similarity (0-1) above which items are treated as one story, 0 matches links only
+	Scoring               ScoringConfig     `json:"scoring"`
+	Translation           TranslationConfig `json:"translation"`
+	FullText              FullTextConfig    `json:"full_text"`
+	Delivery              DeliveryConfig    `json:"delivery"`
+	MetricsFile           string            `json:"metrics_file"`            // Counters of the last run, such as translated characters, empty disables it
+	Languages             []string          `json:"languages"`               // Target language codes, e.g. ["ru", "en", "uk"]
+	Layout                string            `json:"layout"`                  // per_language sends one digest per language, bilingual puts them side by side
+	Channel               string            `json:"channel"`                 // Payload format of the webhook, also sets the message length limit
+	MaxMessageLength      int               `json:"max_message_length"`      // Overrides the channel's limit in characters, 0 keeps it
+	TelegramParseMode     string            `json:"telegram_parse_mode"`     // HTML or MarkdownV2
+	Destinations          []Destination     `json:"destinations"`            // Webhooks to deliver to, besides WEBHOOK_URL and region webhooks
+	Template              string            `json:"template"`                // Digest template file overriding the built-in layout, empty uses the built-in one
+	DescriptionPreviewLen int               `json:"description_preview_len"` // Characters of each description a digest shows
+	Explain               bool              `json:"-"`                       // Print the score breakdown of every ranked item
+
+	digestTemplate *template.Template // Parsed from Template, or the built-in layout
 }
 
 // NewsAggregator is the main struct for the news aggregation service
 type NewsAggregator struct {
-	config Config
-	client *http.Client
-}
-
-// FetchTwitterTrends would fetch X (Twitter) trends
-// Note: This requires Twitter API access which needs authentication
-func (na *NewsAggregator) FetchTwitterTrends() ([]string, error) {
-	// For demonstration, we'll scrape from a trends aggregator
-	url := "https://trends24.in/spain/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var trends []string
-	doc.Find(".trend-card__title").Each(func(i int, s *goquery.Selection) {
-		if i >= 5 { // Top 5 trends
-			return
-		}
-		trend := strings.TrimSpace(s.Text())
-		if trend != "" {
-			trends = append(trends, trend)
-		}
-	})
-
-	return trends, nil
+	config     Config
+	client     *http.Client
+	translator Translator
+	cache      *TranslationCache // nil when the translation cache is disabled
+	usage      usageReporter     // nil when the translator does not meter characters
+	sources    *SourceRegistry
+	regions    []RegionProfile
+	history    *History      // nil when the delivery history is disabled
+	outbox     *Outbox       // nil when undelivered messages are not kept
+	articles   *articlePages // Set for each run by AggregateNews
 }
 
-// FetchMexicoTrends fetches trending topics from Mexico
-func (na *NewsAggregator) FetchMexicoTrends() ([]string, error) {
-	url := "https://trends24.in/mexico/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var trends []string
-
-	// Try multiple selectors for trends24.in
-	doc.Find(".trend-card__title").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 { // Top 10 trends
-			return
-		}
-		trend := strings.TrimSpace(s.Text())
-		if trend != "" {
-			trends = append(trends, trend)
-		}
-	})
-
-	// If no trends found with first selector, try alternatives
-	if len(trends) == 0 {
-		doc.Find("ol.trend-card__list li").Each(func(i int, s *goquery.Selection) {
-			if i >= 10 {
-				return
-			}
-			trend := strings.TrimSpace(s.Find("a").Text())
-			if trend != "" && !strings.Contains(trend, "#") {
-				trends = append(trends, trend)
-			}
-		})
-	}
-
-	return trends, nil
+// Digest is the ranked news and trends of one region, ready to format
+type Digest struct {
+	Region       RegionProfile
+	News         []NewsItem
+	Trends       []string
+	Sources      []string // News sources of the digest's items, including those that also covered them
+	TrendSources []string // Trend pages that returned topics for the region
 }
 
-// NewNewsAggregator creates a new instance of NewsAggregator
-func NewNewsAggregator(webhookURL, deeplAPIKey string) *NewsAggregator {
-	return &NewsAggregator{
-		config: Config{
-			WebhookURL:     webhookURL,
-			DeepLAPIKey:    deeplAPIKey,
-			MaxNewsItems:   5,
-			RequestTimeout: 30 * time.Second,
-			UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		},
+// NewNewsAggregator creates a new instance of NewsAggregator that builds one digest per region.
+// history may be nil, in which case every run is stateless, cache may be nil to always call the translator,
+// and outbox may be nil to drop messages that could not be delivered.
+func NewNewsAggregator(config Config, catalog []SourceSpec, regions []RegionProfile, history *History, cache *TranslationCache, outbox *Outbox) *NewsAggregator {
+	na := &NewsAggregator{
+		config: config,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: config.RequestTimeout.Duration,
 		},
-	}
+		sources: NewSourceRegistry(),
+		regions: regions,
+		history: history,
+		outbox:  outbox,
+	}
+	na.translator = NewTranslator(config, na.client)
+	na.usage, _ = na.translator.(usageReporter)
+	if cache != nil && config.Translation.Provider != ProviderNone {
+		na.cache = cache
+		na.translator = &cachedTranslator{Translator: na.translator, cache: cache}
+	}
+	na.registerDefaultSources(catalog)
+	return na
 }
 
-// TranslateToRussian translates text to Russian using DeepL API
-func (na *NewsAggregator) TranslateToRussian(texts []string) ([]string, error) {
-	if len(texts) == 0 {
-		return []string{}, nil
-	}
-
-	// DeepL API endpoint (use api-free.deepl.com for free tier)
-	url := "https://api-free.deepl.com/v2/translate"
-
-	// Prepare request body
-	data := make(map[string]interface{})
-	data["text"] = texts
-	data["target_lang"] = "RU"
-	data["source_lang"] = "ES" // Spanish source
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "DeepL-Auth-Key "+na.config.DeepLAPIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("DeepL API error: %d - %s", resp.StatusCode, string(body))
-	}
-
-	var result DeepLTranslation
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	translations := make([]string, len(result.Translations))
-	for i, t := range result.Translations {
-		translations[i] = t.Text
-	}
-
-	return translations, nil
-}
-
-// TranslateNewsItems translates all news items to Russian
-func (na *NewsAggregator) TranslateNewsItems(news []NewsItem) []NewsItem {
-	// Batch translation for efficiency
-	var titlesToTranslate []string
-	var descriptionsToTranslate []string
-
-	for _, item := range news {
-		titlesToTranslate = append(titlesToTranslate, item.Title)
-		if item.Description != "" {
-			descriptionsToTranslate = append(descriptionsToTranslate, item.Description)
-		} else {
-			descriptionsToTranslate = append(descriptionsToTranslate, "No description available")
-		}
-	}
-
-	// Translate titles
-	translatedTitles, err := na.TranslateToRussian(titlesToTranslate)
-	if err != nil {
-		log.Printf("Error translating titles: %v", err)
-		// Fall back to original titles
-		for i := range news {
-			news[i].TitleRU = news[i].Title
-		}
-	} else {
-		for i := range news {
-			if i < len(translatedTitles) {
-				news[i].TitleRU = translatedTitles[i]
-			} else {
-				news[i].TitleRU = news[i].Title
+// registerDefaultSources registers the catalog news outlets and the trend pages used by the regions
+func (na *NewsAggregator) registerDefaultSources(catalog []SourceSpec) {
+	var used []SourceSpec
+	for _, spec := range catalog {
+		for _, region := range na.regions {
+			if region.usesSource(spec.Name) {
+				used = append(used, spec)
+				break
 			}
 		}
 	}
+	na.RegisterCatalog(used)
 
-	// Translate descriptions
-	translatedDescriptions, err := na.TranslateToRussian(descriptionsToTranslate)
-	if err != nil {
-		log.Printf("Error translating descriptions: %v", err)
-		// Fall back to original descriptions
-		for i := range news {
-			news[i].DescriptionRU = news[i].Description
-		}
-	} else {
-		for i := range news {
-			if i < len(translatedDescriptions) {
-				news[i].DescriptionRU = translatedDescriptions[i]
-			} else {
-				news[i].DescriptionRU = news[i].Description
+	registered := make(map[string]bool)
+	for _, region := range na.regions {
+		for _, spec := range region.Trends {
+			if registered[spec.Name] {
+				continue
 			}
+			registered[spec.Name] = true
+			na.RegisterSource(TrendSource(spec.Name, func(ctx context.Context) ([]string, error) {
+				return na.fetchTrendPage(ctx, spec)
+			}))
 		}
 	}
-
-	return news
 }
 
-// FetchBBCMundoNews fetches news from BBC Mundo
-func (na *NewsAggregator) FetchBBCMundoNews() ([]NewsItem, error) {
-	urls := []string{
-		"https://feeds.bbci.co.uk/mundo/rss.xml",
-		"https://feeds.bbci.co.uk/mundo/noticias/rss.xml",
-	}
-
-	var allNews []NewsItem
-
-	for _, url := range urls {
-		news, err := na.fetchRSSFeed(url, "BBC Mundo")
-		if err != nil {
-			log.Printf("Error fetching BBC Mundo feed from %s: %v", url, err)
-			// Try web scraping as fallback
-			if scrapedNews, scrapErr := na.scrapeBBCMundo(); scrapErr == nil {
-				allNews = append(allNews, scrapedNews...)
-			}
-			continue
-		}
-		allNews = append(allNews, news...)
-	}
-
-	return na.filterSpainNews(allNews), nil
+// RegisterSource adds a news or trends source to the aggregation run
+func (na *NewsAggregator) RegisterSource(s Source) {
+	na.sources.Register(s)
 }
 
-// scrapeBBCMundo scrapes BBC Mundo website as fallback
-func (na *NewsAggregator) scrapeBBCMundo() ([]NewsItem, error) {
-	urls := []string{
-		"https://www.bbc.com/mundo/topics/c2lej05epw5t",
-		"https://www.bbc.com/mundo/topics/c7zp57yyz25t", // New URL added
+// TranslateNewsItems translates all news items into each of the given languages
+func (na *NewsAggregator) TranslateNewsItems(ctx context.Context, news []NewsItem, languages []string) []NewsItem {
+	for _, lang := range languages {
+		na.translateItems(ctx, news, lang)
 	}
 
-	var allNews []NewsItem
-
-	for _, url := range urls {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("User-Agent", na.config.UserAgent)
-		resp, err := na.client.Do(req)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		if err != nil {
-			continue
-		}
-
-		doc.Find("article").Each(func(i int, s *goquery.Selection) {
-			if len(allNews) >= 10 {
-				return
-			}
-
-			titleElem := s.Find("h3").First()
-			title := strings.TrimSpace(titleElem.Text())
-
-			linkElem := s.Find("a").First()
-			link, _ := linkElem.Attr("href")
-			if !strings.HasPrefix(link, "http") {
-				link = "https://www.bbc.com" + link
-			}
-
-			description := strings.TrimSpace(s.Find("p").First().Text())
-
-			if title != "" && link != "" {
-				allNews = append(allNews, NewsItem{
-					Title:       title,
-					Description: description,
-					Link:        link,
-					Source:      "BBC Mundo",
-					PublishDate: time.Now(),
-				})
-			}
-		})
-	}
-
-	return allNews, nil
-}
-
-// FetchAPNewsLatinAmerica fetches news from AP News Latin America
-func (na *NewsAggregator) FetchAPNewsLatinAmerica() ([]NewsItem, error) {
-	url := "https://apnews.com/hub/latin-america"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var news []NewsItem
-
-	// AP News article structure
-	doc.Find("div[data-key='card-headline']").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
-		}
-
-		titleElem := s.Find("h3").First()
-		if titleElem.Length() == 0 {
-			titleElem = s.Find("h2").First()
-		}
-		title := strings.TrimSpace(titleElem.Text())
-
-		linkElem := s.Find("a").First()
-		link, _ := linkElem.Attr("href")
-		if !strings.HasPrefix(link, "http") {
-			link = "https://apnews.com" + link
-		}
-
-		description := strings.TrimSpace(s.Find("p").First().Text())
-
-		if title != "" && link != "" {
-			news = append(news, NewsItem{
-				Title:       title,
-				Description: description,
-				Link:        link,
-				Source:      "AP News",
-				PublishDate: time.Now(),
-			})
-		}
-	})
-
-	return news, nil
-}
-
-// FetchReutersLatinAmerica fetches news from Reuters Latin America
-func (na *NewsAggregator) FetchReutersLatinAmerica() ([]NewsItem, error) {
-	url := "https://www.reuters.com/world/americas/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var news []NewsItem
-
-	// Reuters article structure
-	doc.Find("article").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
-		}
-
-		titleElem := s.Find("h3").First()
-		if titleElem.Length() == 0 {
-			titleElem = s.Find("h2").First()
-		}
-		title := strings.TrimSpace(titleElem.Text())
-
-		linkElem := s.Find("a").First()
-		link, _ := linkElem.Attr("href")
-		if !strings.HasPrefix(link, "http") {
-			link = "https://www.reuters.com" + link
-		}
-
-		description := strings.TrimSpace(s.Find("p").First().Text())
-
-		if title != "" && link != "" {
-			news = append(news, NewsItem{
-				Title:       title,
-				Description: description,
-				Link:        link,
-				Source:      "Reuters",
-				PublishDate: time.Now(),
-			})
-		}
-	})
-
-	return news, nil
-}
-
-// FetchFoxNewsLatinAmerica fetches news from Fox News Latin America
-func (na *NewsAggregator) FetchFoxNewsLatinAmerica() ([]NewsItem, error) {
-	url := "https://www.foxnews.com/category/world/world-regions/latin-america"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
+	if na.cache != nil {
+		hits, misses := na.cache.TakeStats()
+		log.Printf("Translation cache: %d hits, %d misses", hits, misses)
 	}
-
-	var news []NewsItem
-
-	// Fox News article structure
-	doc.Find("article").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
-		}
-
-		titleElem := s.Find("h3").First()
-		if titleElem.Length() == 0 {
-			titleElem = s.Find("h2").First()
-		}
-		title := strings.TrimSpace(titleElem.Text())
-
-		linkElem := s.Find("a").First()
-		link, _ := linkElem.Attr("href")
-		if !strings.HasPrefix(link, "http") {
-			link = "https://www.foxnews.com" + link
-		}
-
-		description := strings.TrimSpace(s.Find("p").First().Text())
-
-		if title != "" && link != "" {
-			news = append(news, NewsItem{
-				Title:       title,
-				Description: description,
-				Link:        link,
-				Source:      "Fox News",
-				PublishDate: time.Now(),
-			})
-		}
-	})
-
-	return news, nil
-}
-
-// FetchElUniversalMexico fetches news from El Universal Mexico
-func (na *NewsAggregator) FetchElUniversalMexico() ([]NewsItem, error) {
-	// Try RSS feed first
-	rssURL := "https://www.eluniversal.com.mx/rss.xml"
-	news, err := na.fetchRSSFeed(rssURL, "El Universal México")
-	if err == nil && len(news) > 0 {
-		return news, nil
-	}
-
-	// Fallback to web scraping
-	url := "https://www.eluniversal.com.mx/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var newsItems []NewsItem
-
-	// El Universal article structure
-	doc.Find("article").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
-		}
-
-		titleElem := s.Find("h2 a, h3 a").First()
-		title := strings.TrimSpace(titleElem.Text())
-		link, _ := titleElem.Attr("href")
-
-		if !strings.HasPrefix(link, "http") {
-			link = "https://www.eluniversal.com.mx" + link
-		}
-
-		description := strings.TrimSpace(s.Find("p").First().Text())
-
-		if title != "" && link != "" {
-			newsItems = append(newsItems, NewsItem{
-				Title:       title,
-				Description: description,
-				Link:        link,
-				Source:      "El Universal México",
-				PublishDate: time.Now(),
-			})
-		}
-	})
-
-	return newsItems, nil
+	return news
 }
 
-// FetchElPaisMexico fetches news from El País Mexico section
-func (na *NewsAggregator) FetchElPaisMexico() ([]NewsItem, error) {
-	// Try RSS feed first
-	rssURL := "https://feeds.elpais.com/mrss-s/pages/ep/site/elpais.com/section/mexico/portada"
-	news, err := na.fetchRSSFeed(rssURL, "El País México")
-	if err == nil && len(news) > 0 {
-		return news, nil
-	}
-
-	// Fallback to web scraping
-	url := "https://elpais.com/noticias/mexico/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var newsItems []NewsItem
-
-	// El País article structure
-	doc.Find("article").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
-		}
-
-		titleElem := s.Find("h2 a").First()
-		title := strings.TrimSpace(titleElem.Text())
-		link, _ := titleElem.Attr("href")
-
-		if !strings.HasPrefix(link, "http") {
-			link = "https://elpais.com" + link
+// translateItems translates the titles and descriptions of the items into one language.
+// Items are batched by source language so the translator gets the right hint,
+// and items already written in the target language are left as they are. Each
+// item records whether its translation succeeded, so a failure never silently
+// mixes languages.
+func (na *NewsAggregator) translateItems(ctx context.Context, news []NewsItem, lang string) {
+	translations := make([]Translation, len(news))
+
+	// Group items by their source language, keeping the original order within each group
+	var order []string
+	groups := make(map[string][]int)
+	for i, item := range news {
+		translations[i] = Translation{Title: item.Title, Description: item.Description, Status: TranslationOriginal}
+		if item.Language == lang {
+			continue
 		}
-
-		description := strings.TrimSpace(s.Find("p").First().Text())
-
-		if title != "" && link != "" {
-			newsItems = append(newsItems, NewsItem{
-				Title:       title,
-				Description: description,
-				Link:        link,
-				Source:      "El País México",
-				PublishDate: time.Now(),
-			})
+		if _, ok := groups[item.Language]; !ok {
+			order = append(order, item.Language)
 		}
-	})
-
-	return newsItems, nil
-}
-
-// FetchCNNEspanolNews fetches news from CNN en Español
-func (na *NewsAggregator) FetchCNNEspanolNews() ([]NewsItem, error) {
-	// Try multiple CNN en Español sections
-	urls := []string{
-		"https://cnnespanol.cnn.com/category/espana/",
-		"https://cnnespanol.cnn.com/latinoamerica/",
+		groups[item.Language] = append(groups[item.Language], i)
 	}
 
-	var allNews []NewsItem
+	for _, sourceLang := range order {
+		indices := groups[sourceLang]
 
-	for _, url := range urls {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			continue
+		// Titles and non-empty descriptions go out together in as few batches as possible
+		var texts []string
+		descriptionAt := make(map[int]int) // item index -> position of its description in texts
+		for _, i := range indices {
+			texts = append(texts, news[i].Title)
 		}
-
-		req.Header.Set("User-Agent", na.config.UserAgent)
-		resp, err := na.client.Do(req)
-		if err != nil {
-			continue
+		for _, i := range indices {
+			if news[i].Description == "" {
+				continue
+			}
+			description := news[i].Description
+			if maxLen := na.config.Translation.MaxDescriptionLen; maxLen > 0 {
+				description = truncateString(description, maxLen)
+			}
+			descriptionAt[i] = len(texts)
+			texts = append(texts, description)
 		}
-		defer resp.Body.Close()
 
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		if err != nil {
-			continue
-		}
+		// An empty source language lets the translator detect it
+		translated, ok := na.translateTexts(ctx, texts, sourceLang, lang)
 
-		// Parse CNN articles
-		doc.Find("article").Each(func(i int, s *goquery.Selection) {
-			if len(allNews) >= 15 { // Limit total articles
-				return
+		for j, i := range indices {
+			titleOK := ok[j]
+			k, hasDescription := descriptionAt[i]
+			descriptionOK := !hasDescription || ok[k]
+			if hasDescription && descriptionOK {
+				translations[i].Description = translated[k]
 			}
-
-			titleElem := s.Find("h3 a").First()
-			title := strings.TrimSpace(titleElem.Text())
-			link, _ := titleElem.Attr("href")
-
-			if !strings.HasPrefix(link, "http") {
-				link = "https://cnnespanol.cnn.com" + link
+			if titleOK {
+				translations[i].Title = translated[j]
 			}
 
-			description := strings.TrimSpace(s.Find(".news__excerpt").Text())
-			if description == "" {
-				description = strings.TrimSpace(s.Find("p").First().Text())
+			switch {
+			case titleOK && descriptionOK:
+				translations[i].Status = TranslationDone
+			case titleOK:
+				translations[i].Status = TranslationPartial
+			case hasDescription && descriptionOK:
+				translations[i].Status = TranslationTitleFailed
+			default:
+				translations[i].Status = TranslationFailed
 			}
+		}
 
-			if title != "" && link != "" {
-				allNews = append(allNews, NewsItem{
-					Title:       title,
-					Description: description,
-					Link:        link,
-					Source:      "CNN en Español",
-					PublishDate: time.Now(), // CNN doesn't always show dates on listing
-				})
+		// Items sent without a hint take the language the translator detected in their title
+		if detector, ok := na.translator.(languageDetector); ok && sourceLang == "" {
+			for _, i := range indices {
+				news[i].Language = detector.DetectedLanguage(news[i].Title)
 			}
-		})
-	}
-
-	return allNews, nil
-}
-
-// FetchGoogleTrends fetches trending topics from Google Trends Spain
-func (na *NewsAggregator) FetchGoogleTrends() ([]string, error) {
-	// Google Trends doesn't provide a public RSS feed anymore
-	// We'll scrape from trends aggregator websites instead
-	url := "https://trends.google.com/trends/trendingsearches/daily?geo=ES"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Google Trends uses JavaScript rendering, so we'll use an alternative approach
-	// Fetch from a trends aggregator that provides Spanish trends
-	return na.fetchTrendsFromAggregator()
-}
-
-// fetchTrendsFromAggregator fetches trends from aggregator sites
-func (na *NewsAggregator) fetchTrendsFromAggregator() ([]string, error) {
-	// Using getdaytrends as it provides real-time trends data
-	url := "https://getdaytrends.com/spain/"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", na.config.UserAgent)
-	resp, err := na.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
+		}
 	}
 
-	var trends []string
-
-	// Look for trending topics on the page
-	doc.Find(".trend-name").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 { // Limit to top 10
-			return
+	failed := 0
+	for i := range news {
+		if news[i].Translations == nil {
+			news[i].Translations = make(map[string]Translation)
 		}
-		trend := strings.TrimSpace(s.Text())
-		if trend != "" && !strings.Contains(trend, "...") {
-			trends = append(trends, trend)
+		news[i].Translations[lang] = translations[i]
+		if status := translations[i].Status; status != TranslationDone && status != TranslationOriginal {
+			failed++
 		}
-	})
-
-	// If the above selector doesn't work, try alternative selectors
-	if len(trends) == 0 {
-		doc.Find("a[href*='/trend/']").Each(func(i int, s *goquery.Selection) {
-			if i >= 10 {
-				return
-			}
-			trend := strings.TrimSpace(s.Text())
-			if trend != "" && len(trend) > 2 && !strings.Contains(trend, "...") {
-				trends = append(trends, trend)
-			}
-		})
 	}
-
-	return trends, nil
+	if failed > 0 {
+		log.Printf("%d of %d items could not be fully translated to %s", failed, len(news), lang)
+	}
 }
 
 // fetchRSSFeed is a helper to fetch and parse RSS feeds
-func (na *NewsAggregator) fetchRSSFeed(url, source string) ([]NewsItem, error) {
+func (na *NewsAggregator) fetchRSSFeed(ctx context.Context, url, source string) ([]NewsItem, error) {
 	fp := gofeed.NewParser()
-	feed, err := fp.ParseURL(url)
+	fp.UserAgent = na.config.UserAgent
+	fp.Client = na.client
+	feed, err := fp.ParseURLWithContext(url, ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	feedLang := normalizeLangCode(feed.Language)
+
 	var news []NewsItem
 	for _, item := range feed.Items {
-		publishDate := time.Now()
+		var publishDate time.Time
+		dateUnknown := false
 		if item.PublishedParsed != nil {
 			publishDate = *item.PublishedParsed
-		}
-
-		// Only include news from last 24 hours
-		if time.Since(publishDate) > 24*time.Hour {
-			continue
+		} else if item.UpdatedParsed != nil {
+			publishDate = *item.UpdatedParsed
+		} else {
+			dateUnknown = true
 		}
 
 		news = append(news, NewsItem{
-			Title:       item.Title,
-			Description: item.Description,
+			Title:       cleanText(item.Title),
+			Description: feedItemDescription(item),
 			Link:        item.Link,
 			Source:      source,
+			Language:    feedLang,
 			PublishDate: publishDate,
+			DateUnknown: dateUnknown,
 		})
 	}
 
-	return news, nil
+	// Only include news from last 24 hours
+	return dropStale(news), nil
 }
 
-// filterSpainNews filters news items to only include Spain-related content
-func (na *NewsAggregator) filterSpainNews(news []NewsItem) []NewsItem {
-	spainKeywords := []string{
-		"españa", "spain", "español", "española",
-		"madrid", "barcelona", "valencia", "sevilla",
-		"gobierno español", "pedro sánchez", "rey felipe",
-		"la moncloa", "congreso de los diputados",
+// excludeDelivered drops items that were already sent to the region within the history window
+func (na *NewsAggregator) excludeDelivered(region string, news []NewsItem) []NewsItem {
+	if na.history == nil {
+		return news
 	}
 
-	var filtered []NewsItem
+	now := time.Now()
+	var fresh []NewsItem
 	for _, item := range news {
-		content := strings.ToLower(item.Title + " " + item.Description)
-
-		for _, keyword := range spainKeywords {
-			if strings.Contains(content, keyword) {
-				item.Score = calculateRelevanceScore(item, spainKeywords)
-				filtered = append(filtered, item)
-				break
-			}
+		if !na.history.WasSent(region, item, na.config.HistoryWindow.Duration, now) {
+			fresh = append(fresh, item)
 		}
 	}
 
-	return filtered
-}
-
-// calculateRelevanceScore calculates a relevance score for ranking
-func calculateRelevanceScore(item NewsItem, keywords []string) int {
-	score := 0
-	content := strings.ToLower(item.Title + " " + item.Description)
-
-	// More recent = higher score
-	hoursSincePublish := int(time.Since(item.PublishDate).Hours())
-	if hoursSincePublish < 1 {
-		score += 100
-	} else if hoursSincePublish < 6 {
-		score += 50
-	} else if hoursSincePublish < 12 {
-		score += 25
-	}
-
-	// Count keyword matches
-	for _, keyword := range keywords {
-		if strings.Contains(content, keyword) {
-			score += 10
-		}
+	if skipped := len(news) - len(fresh); skipped > 0 {
+		log.Printf("[%s] Skipped %d news items already sent in the last %s", region, skipped, na.config.HistoryWindow.Duration)
 	}
+	return fresh
+}
 
-	// Title matches are worth more
-	titleLower := strings.ToLower(item.Title)
-	for _, keyword := range keywords {
-		if strings.Contains(titleLower, keyword) {
-			score += 20
-		}
+// recordDelivered stores the delivered items in the history so later runs skip them
+func (na *NewsAggregator) recordDelivered(region string, news []NewsItem) error {
+	if na.history == nil {
+		return nil
 	}
-
-	return score
+	na.history.Record(region, news, time.Now())
+	return na.history.Save()
 }
 
 // rankNewsByRelevance sorts news by relevance score
@@ -873,6 +336,10 @@ func (na *NewsAggregator) rankNewsByRelevance(news []NewsItem) []NewsItem {
 		}
 	}
 
+	if na.config.Explain {
+		writeScoreExplanation(os.Stdout, news)
+	}
+
 	// Return top N items
 	if len(news) > na.config.MaxNewsItems {
 		return news[:na.config.MaxNewsItems]
@@ -880,214 +347,282 @@ func (na *NewsAggregator) rankNewsByRelevance(news []NewsItem) []NewsItem {
 	return news
 }
 
-// AggregateNews combines all news sources and trends
-func (na *NewsAggregator) AggregateNews() ([]NewsItem, []string, error) {
-	// Fetch news from different sources
-	var allNews []NewsItem
-
-	// BBC Mundo
-	bbcNews, err := na.FetchBBCMundoNews()
-	if err != nil {
-		log.Printf("Error fetching BBC Mundo news: %v", err)
-	} else {
-		allNews = append(allNews, bbcNews...)
+// regionLanguages returns the languages the destinations taking a region's
+// digest ask for, so no quota is spent on a translation nobody receives
+func (na *NewsAggregator) regionLanguages(code string) []string {
+	if len(na.config.Destinations) == 0 {
+		return na.config.Languages
 	}
-
-	// CNN en Español
-	cnnNews, err := na.FetchCNNEspanolNews()
-	if err != nil {
-		log.Printf("Error fetching CNN news: %v", err)
-	} else {
-		allNews = append(allNews, cnnNews...)
+	var languages []string
+	for _, dest := range na.config.Destinations {
+		if !dest.covers(code) {
+			continue
+		}
+		for _, lang := range dest.Languages {
+			if !slices.Contains(languages, lang) {
+				languages = append(languages, lang)
+			}
+		}
 	}
+	return languages
+}
 
-	// AP News Latin America
-	apNews, err := na.FetchAPNewsLatinAmerica()
-	if err != nil {
-		log.Printf("Error fetching AP News: %v", err)
-	} else {
-		allNews = append(allNews, apNews...)
+// errNoNewNews is returned when every fetched item was already delivered
+var errNoNewNews = errors.New("no new news items since the last delivery")
+
+// AggregateNews fetches every source once and builds the digest of each region.
+// Regions without anything new are left out; an error is returned only when
+// the run was cancelled or no region could be built at all.
+func (na *NewsAggregator) AggregateNews(ctx context.Context) ([]Digest, error) {
+	// Article pages are downloaded under the run's context, not that of
+	// whichever source asked first, so one source's deadline does not fail the others
+	na.articles = newArticlePages(ctx, na.config.MaxConcurrentFetches)
+
+	// Fetch news and trends from every registered source
+	results := na.fetchSources(ctx)
+
+	var digests []Digest
+	var lastErr error
+	for _, region := range na.regions {
+		digest, err := na.aggregateRegion(ctx, region, results)
+		if errors.Is(err, errNoNewNews) {
+			log.Printf("[%s] Nothing new to send", region.Code)
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("[%s] Error aggregating news: %v", region.Code, err)
+			lastErr = err
+			continue
+		}
+		digests = append(digests, digest)
 	}
 
-	// Reuters Latin America
-	reutersNews, err := na.FetchReutersLatinAmerica()
-	if err != nil {
-		log.Printf("Error fetching Reuters news: %v", err)
-	} else {
-		allNews = append(allNews, reutersNews...)
+	if len(digests) == 0 && lastErr != nil {
+		return nil, lastErr
 	}
+	return digests, nil
+}
 
-	// Fox News Latin America
-	foxNews, err := na.FetchFoxNewsLatinAmerica()
-	if err != nil {
-		log.Printf("Error fetching Fox News: %v", err)
-	} else {
-		allNews = append(allNews, foxNews...)
+// aggregateRegion runs the filter, scoring, ranking and translation pipeline for one region
+func (na *NewsAggregator) aggregateRegion(ctx context.Context, region RegionProfile, results map[string]SourceResult) (Digest, error) {
+	// Collect the region's news and trends in registration order, noting
+	// which sources returned any
+	var allNews []NewsItem
+	var trendingTopics []string
+	var newsSources, trendSources []string
+	for _, src := range na.sources.All() {
+		result, ok := results[src.Name()]
+		if !ok {
+			continue
+		}
+		if src.Kind() == SourceKindNews && region.usesSource(src.Name()) && len(result.News) > 0 {
+			allNews = append(allNews, result.News...)
+			newsSources = append(newsSources, src.Name())
+		}
+		if src.Kind() == SourceKindTrends && region.usesTrends(src.Name()) && len(result.Trends) > 0 {
+			trendingTopics = append(trendingTopics, result.Trends...)
+			trendSources = append(trendSources, src.Name())
+		}
 	}
 
-	// El Universal Mexico
-	elUniversalNews, err := na.FetchElUniversalMexico()
-	if err != nil {
-		log.Printf("Error fetching El Universal news: %v", err)
-	} else {
-		allNews = append(allNews, elUniversalNews...)
+	// Ensure we have at least some news
+	if len(allNews) == 0 {
+		return Digest{}, fmt.Errorf("no news items could be fetched from any source")
 	}
 
-	// El País Mexico
-	elPaisMexicoNews, err := na.FetchElPaisMexico()
-	if err != nil {
-		log.Printf("Error fetching El País Mexico news: %v", err)
-	} else {
-		allNews = append(allNews, elPaisMexicoNews...)
+	scoring := na.config.Scoring
+	if len(region.Keywords) > 0 {
+		scoring.Keywords = region.Keywords
 	}
 
-	// Additional Spanish news sources
-	additionalNews, err := na.FetchAdditionalSpanishNews()
-	if err != nil {
-		log.Printf("Error fetching additional news: %v", err)
-	} else {
-		allNews = append(allNews, additionalNews...)
+	// Filter every item by its source's policy
+	allNews = na.applyFilterPolicies(allNews, scoring.Keywords)
+	if len(allNews) == 0 {
+		return Digest{}, fmt.Errorf("no news items related to %s were found", region.Name)
 	}
 
-	// Ensure we have at least some news
+	// Leave out stories that earlier runs already delivered
+	allNews = na.excludeDelivered(region.Code, allNews)
 	if len(allNews) == 0 {
-		return nil, nil, fmt.Errorf("no news items could be fetched from any source")
+		return Digest{}, errNoNewNews
 	}
 
-	// Rank by relevance
-	topNews := na.rankNewsByRelevance(allNews)
-
-	// Translate the top news items to Russian
-	topNews = na.TranslateNewsItems(topNews)
+	// Remove duplicates from trends
+	trendingTopics = removeDuplicates(trendingTopics)
 
-	// Fetch trending topics
-	var trendingTopics []string
+	// Score items on their own so clustering can pick the best representative,
+	// then merge reports of the same story and score again now that coverage is known
+	trends := newTrendMatcher(trendingTopics)
+	scorer := NewScorer(scoring, trends)
+	scorer.Score(allNews)
+	allNews = clusterStories(allNews, na.config.ClusterSimilarity)
+	scorer.Score(allNews)
 
-	googleTrends, err := na.FetchGoogleTrends()
-	if err != nil {
-		log.Printf("Error fetching Google Trends: %v", err)
-	} else {
-		trendingTopics = append(trendingTopics, googleTrends...)
+	// Read the articles of the leading stories and score them again with their full text
+	if na.config.FullText.Enabled {
+		na.extractFullTexts(ctx, allNews, scoring.Keywords)
+		scorer.Score(allNews)
 	}
 
-	twitterTrends, err := na.FetchTwitterTrends()
-	if err != nil {
-		log.Printf("Error fetching Twitter trends: %v", err)
-	} else {
-		trendingTopics = append(trendingTopics, twitterTrends...)
+	// Rank by relevance
+	topNews := na.rankNewsByRelevance(allNews)
+
+	// Note which trends each selected story is tied to
+	for i := range topNews {
+		topNews[i].RelatedTrends = trends.Match(topNews[i])
 	}
 
-	// Fetch Mexico trends
-	mexicoTrends, err := na.FetchMexicoTrends()
-	if err != nil {
-		log.Printf("Error fetching Mexico trends: %v", err)
-	} else {
-		trendingTopics = append(trendingTopics, mexicoTrends...)
+	// Stop before translating if the run was cancelled while fetching
+	if err := ctx.Err(); err != nil {
+		return Digest{}, err
 	}
 
-	// Remove duplicates from trends
-	trendingTopics = removeDuplicates(trendingTopics)
+	// Translate the top news items into the languages the region's destinations receive
+	languages := na.regionLanguages(region.Code)
+	log.Printf("[%s] Translating %d news items to %s", region.Code, len(topNews), strings.Join(languages, ", "))
+	topNews = na.TranslateNewsItems(ctx, topNews, languages)
 
 	// Don't translate trending topics - keep them in original language
 
-	return topNews, trendingTopics, nil
+	return Digest{Region: region, News: topNews, Trends: trendingTopics, Sources: digestSources(newsSources, topNews), TrendSources: trendSources}, nil
 }
 
-// FetchAdditionalSpanishNews fetches news from additional Spanish sources
-func (na *NewsAggregator) FetchAdditionalSpanishNews() ([]NewsItem, error) {
-	// El País RSS feed
-	elpaisNews, err := na.fetchRSSFeed("https://feeds.elpais.com/mrss-s/pages/ep/site/elpais.com/section/espana/portada", "El País")
-	if err != nil {
-		log.Printf("Error fetching El País feed: %v", err)
-	}
-
-	// Europa Press RSS
-	europaNews, err := na.fetchRSSFeed("https://www.europapress.es/rss/rss.aspx", "Europa Press")
-	if err != nil {
-		log.Printf("Error fetching Europa Press feed: %v", err)
-	}
-
-	var allNews []NewsItem
-	if elpaisNews != nil {
-		allNews = append(allNews, elpaisNews...)
+// digestSources returns the sources, in registration order, that reported
+// one of the given stories, whether as its source or as another outlet
+// covering it
+func digestSources(sources []string, news []NewsItem) []string {
+	contributed := make(map[string]bool)
+	for _, item := range news {
+		contributed[item.Source] = true
+		for _, src := range item.AlsoCoveredBy {
+			contributed[src] = true
+		}
 	}
-	if europaNews != nil {
-		allNews = append(allNews, europaNews...)
+	var used []string
+	for _, src := range sources {
+		if contributed[src] {
+			used = append(used, src)
+		}
 	}
-
-	return na.filterSpainNews(allNews), nil
+	return used
 }
 
-// FormatNewsAsString formats the news and trends into a ready-to-use string
-func (na *NewsAggregator) FormatNewsAsString(topNews []NewsItem, trends []string) string {
-	var sb strings.Builder
-
-	// Header
-	sb.WriteString("🇪🇸 **TOP 5 SPAIN NEWS** 🇪🇸\n")
-	sb.WriteString(fmt.Sprintf("📅 %s\n", time.Now().Format("January 2, 2006 - 15:04 MST")))
-	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
-
-	// News items
-	for i, news := range topNews {
-		// Use Russian title if available, otherwise fallback to original
-		title := news.TitleRU
-		if title == "" {
-			title = news.Title
-		}
-
-		sb.WriteString(fmt.Sprintf("📰 **%d. %s**\n", i+1, title))
-		sb.WriteString(fmt.Sprintf("📍 Source: %s\n", news.Source))
+// formatDigest renders a region's digest as the messages to post to a
+// destination: one per language of the destination, or a single bilingual one
+// with the bilingual layout, each in the payload format of its channel.
+// Digests longer than the channel allows are shortened or split.
+func (na *NewsAggregator) formatDigest(digest Digest, dest Destination) ([]webhookMessage, error) {
+	adapter, err := na.outputAdapter(dest.Channel, dest.URL)
+	if err != nil {
+		return nil, err
+	}
 
-		// Use Russian description if available
-		description := news.DescriptionRU
-		if description == "" {
-			description = news.Description
+	var groups [][]string
+	switch {
+	case na.config.Layout == LayoutBilingual:
+		groups = append(groups, dest.Languages)
+	case len(dest.Languages) == 0:
+		groups = append(groups, nil)
+	default:
+		for _, lang := range dest.Languages {
+			groups = append(groups, []string{lang})
 		}
+	}
 
-		if description != "" && description != "No description available" {
-			description = truncateString(description, 150)
-			sb.WriteString(fmt.Sprintf("📝 %s\n", description))
+	var messages []webhookMessage
+	for _, languages := range groups {
+		rendered, err := adapter.render(na.newDigestView(digest, languages))
+		if err != nil {
+			return nil, fmt.Errorf("error rendering %s message: %v", dest.Channel, err)
 		}
-
-		sb.WriteString(fmt.Sprintf("🔗 %s\n", news.Link))
-		sb.WriteString("\n")
+		messages = append(messages, rendered...)
 	}
+	return messages, nil
+}
 
-	// Trending topics
-	sb.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString("🔥 **TRENDING IN SPAIN** 🔥\n\n")
-
-	if len(trends) == 0 {
-		sb.WriteString("No trending topics available at this time.\n")
-	} else {
-		for i, trend := range trends {
-			if i >= 10 {
-				break
+// newDigestView collects what a message shows of a digest, with titles and
+// descriptions in each of the given languages in turn
+func (na *NewsAggregator) newDigestView(digest Digest, languages []string) digestView {
+	region := digest.Region
+	if len(languages) == 0 {
+		languages = []string{""}
+	}
+
+	view := digestView{
+		RegionCode:   region.Code,
+		RegionName:   region.Name,
+		Languages:    languages,
+		Flag:         region.Flag,
+		Date:         time.Now(),
+		Count:        len(digest.News),
+		TrendsHeader: region.TrendsHeader,
+		Trends:       digest.Trends[:min(len(digest.Trends), maxDigestTrends)],
+		NewsSources:  digest.Sources,
+		TrendSources: digest.TrendSources,
+		PreviewLen:   na.config.DescriptionPreviewLen,
+	}
+
+	for i, news := range digest.News {
+		item := itemView{
+			Number:        i + 1,
+			Title:         news.TitleIn(languages[0]),
+			Source:        news.Source,
+			RelatedTrends: news.RelatedTrends,
+			AlsoCoveredBy: news.AlsoCoveredBy,
+			Link:          news.Link,
+		}
+		if !news.DateUnknown {
+			item.PublishDate = news.PublishDate
+		}
+		for _, lang := range languages[1:] {
+			item.OtherTitles = append(item.OtherTitles, news.TitleIn(lang))
+		}
+		for _, lang := range languages {
+			switch news.TranslationStatus(lang) {
+			case TranslationFailed:
+				item.Warnings = append(item.Warnings, fmt.Sprintf("Not translated to %s, original text shown", strings.ToUpper(lang)))
+			case TranslationPartial:
+				item.Warnings = append(item.Warnings, fmt.Sprintf("Description not translated to %s", strings.ToUpper(lang)))
+			case TranslationTitleFailed:
+				item.Warnings = append(item.Warnings, fmt.Sprintf("Title not translated to %s", strings.ToUpper(lang)))
 			}
-			sb.WriteString(fmt.Sprintf("• %s\n", trend))
+			item.Descriptions = append(item.Descriptions, news.DescriptionIn(lang))
 		}
+		view.Items = append(view.Items, item)
 	}
 
-	sb.WriteString("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	sb.WriteString("📊 Sources: BBC Mundo, CNN Español, El País, Europa Press, AP News, Reuters, Fox News, El Universal México, El País México\n")
-	sb.WriteString("🔍 Trends: Google Trends Spain, X (Twitter) Spain, Mexico Trends")
-
-	return sb.String()
+	// The header may refer to the rest of the view, e.g. "TOP {{.Count}} SPAIN NEWS"
+	view.Header = expandHeader(region.Header, view)
+	return view
 }
 
-// SendToWebhook sends the formatted string to the specified webhook
-func (na *NewsAggregator) SendToWebhook(message string) error {
-	// Create a simple text/plain request
-	req, err := http.NewRequest("POST", na.config.WebhookURL, bytes.NewBufferString(message))
-	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
-	}
-
-	// Set content type to plain text
-	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
-	req.Header.Set("User-Agent", na.config.UserAgent)
+// SendToWebhook posts a rendered message to a destination's webhook, signed
+// when the destination has a secret, retrying network errors, 429 and 5xx
+// responses as the delivery config allows
+func (na *NewsAggregator) SendToWebhook(ctx context.Context, dest Destination, message webhookMessage) error {
+	resp, err := doWithRetry(ctx, na.client, na.config.Delivery.retryPolicy(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", dest.URL, bytes.NewReader(message.Body))
+		if err != nil {
+			return nil, err
+		}
 
-	resp, err := na.client.Do(req)
+		req.Header.Set("Content-Type", message.ContentType)
+		req.Header.Set("User-Agent", na.config.UserAgent)
+		if message.IdempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", message.IdempotencyKey)
+		}
+		for k, v := range dest.Headers {
+			req.Header.Set(k, v)
+		}
+		// Signed anew on every attempt so the timestamp stays current
+		if dest.signingSecret != "" {
+			webhooksig.SignRequest(req, []byte(dest.signingSecret), message.Body, time.Now())
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("error sending webhook: %v", err)
 	}
@@ -1095,10 +630,10 @@ func (na *NewsAggregator) SendToWebhook(message string) error {
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
+		return &webhookError{Status: resp.StatusCode, Body: string(body)}
 	}
 
-	log.Printf("Successfully sent news to webhook. Status: %d", resp.StatusCode)
+	log.Printf("Successfully sent news to %s. Status: %d", dest.Name, resp.StatusCode)
 	return nil
 }
 
@@ -1117,65 +652,264 @@ func removeDuplicates(items []string) []string {
 	return result
 }
 
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// Run executes the news aggregation and sends each region's digest to its webhook.
+// Cancelling ctx aborts any request in flight and ends the run early.
+func (na *NewsAggregator) Run(ctx context.Context) error {
+	log.Printf("Starting news aggregation for %d regions...", len(na.regions))
+
+	// Keep whatever was translated, even when the run fails or is interrupted
+	if na.cache != nil {
+		defer func() {
+			if err := na.cache.Save(); err != nil {
+				log.Printf("Error saving translation cache: %v", err)
+			}
+		}()
 	}
-	// Find last space before maxLen to avoid cutting words
-	lastSpace := strings.LastIndex(s[:maxLen], " ")
-	if lastSpace > 0 {
-		return s[:lastSpace] + "..."
+	if na.usage != nil {
+		defer na.usage.LogUsage()
+	}
+	if na.config.MetricsFile != "" {
+		started := time.Now()
+		defer func() {
+			if err := na.saveRunMetrics(started); err != nil {
+				log.Printf("Error saving run metrics: %v", err)
+			}
+		}()
 	}
-	return s[:maxLen] + "..."
-}
 
-// Run executes the news aggregation and webhook sending
-func (na *NewsAggregator) Run() error {
-	log.Println("Starting Spain news aggregation with Russian translation...")
+	// Messages earlier runs could not deliver go out before the new digests
+	na.drainOutbox(ctx)
+	if na.outbox != nil {
+		defer func() {
+			if err := na.outbox.Save(); err != nil {
+				log.Printf("Error saving outbox: %v", err)
+			}
+		}()
+	}
 
-	topNews, trends, err := na.AggregateNews()
+	digests, err := na.AggregateNews(ctx)
 	if err != nil {
 		return fmt.Errorf("error aggregating news: %v", err)
 	}
+	if len(digests) == 0 {
+		log.Println("Nothing new to send, skipping delivery")
+		return nil
+	}
+
+	// Deliver every digest to each destination that takes its region; a
+	// failing destination is reported and the others are still served
+	sent := make(map[string]int)
+	queued := make(map[string]int)
+	failed := make(map[string]int)
+	deliveries, failures := 0, 0
+	for _, digest := range digests {
+		code := digest.Region.Code
+		log.Printf("[%s] Aggregated %d news items and %d trending topics",
+			code, len(digest.News), len(digest.Trends))
+
+		delivered := false
+		for _, dest := range na.config.Destinations {
+			if !dest.covers(code) {
+				continue
+			}
+			// After a cancellation deliver still runs, queueing the digest for the next run
+			deliveries++
+			result, err := na.deliver(ctx, digest, dest)
+			// Once any message reached the destination or waits in the outbox the
+			// story counts as delivered, so no message of it is posted twice
+			if result.sent > 0 || result.queued > 0 {
+				delivered = true
+			}
+			switch {
+			case err == nil:
+				sent[dest.Name]++
+			case result.queued > 0:
+				// The outbox sends the rest on the next run
+				log.Printf("[%s] Error delivering to %s, %d messages queued for the next run: %v", code, dest.Name, result.queued, err)
+				queued[dest.Name]++
+			default:
+				log.Printf("[%s] Error delivering to %s, %d of %d messages sent: %v", code, dest.Name, result.sent, result.sent+result.failed, err)
+				failed[dest.Name]++
+				failures++
+			}
+		}
+		if delivered {
+			if err := na.recordDelivered(code, digest.News); err != nil {
+				return fmt.Errorf("error recording delivered news: %v", err)
+			}
+		}
+		// Digests not reached yet are built again by the next run
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
 
-	log.Printf("Aggregated %d news items and %d trending topics",
-		len(topNews), len(trends))
+	for _, dest := range na.config.Destinations {
+		if sent[dest.Name] > 0 || queued[dest.Name] > 0 || failed[dest.Name] > 0 {
+			log.Printf("Destination %s: %d digests delivered, %d queued, %d failed",
+				dest.Name, sent[dest.Name], queued[dest.Name], failed[dest.Name])
+		}
+	}
 
-	// Format as string
-	formattedMessage := na.FormatNewsAsString(topNews, trends)
+	if failures > 0 {
+		return fmt.Errorf("%d of %d deliveries failed", failures, deliveries)
+	}
+	return nil
+}
 
-	// Print to console
-	fmt.Println("\n=== FORMATTED MESSAGE ===")
-	fmt.Println(formattedMessage)
-	fmt.Println("\n=== END OF MESSAGE ===")
+// errDestinationBacklog is returned for a digest queued behind messages the destination has not taken yet
+var errDestinationBacklog = errors.New("earlier messages to the destination are still queued")
 
-	// Send to webhook
-	if err := na.SendToWebhook(formattedMessage); err != nil {
-		return fmt.Errorf("error sending to webhook: %v", err)
-	}
+// deliveryResult counts what became of the messages of one digest at one destination
+type deliveryResult struct {
+	sent   int
+	queued int // Left in the outbox for the next run
+	failed int // Rejected outright, they would fail again
+}
 
-	return nil
+// deliver renders a digest for a destination and posts every message to it.
+// A message that may pass later, or was not sent because ctx was cancelled,
+// is queued in the outbox together with the messages after it, so they keep
+// their order; a message rejected outright is dropped and the rest are still
+// sent. While older messages to the destination wait in the outbox, the whole
+// digest is queued behind them. The last error is returned.
+func (na *NewsAggregator) deliver(ctx context.Context, digest Digest, dest Destination) (deliveryResult, error) {
+	var result deliveryResult
+	messages, err := na.formatDigest(digest, dest)
+	if err != nil {
+		return result, err
+	}
+
+	for i := range messages {
+		messages[i].IdempotencyKey = idempotencyKey(dest, messages[i].Body)
+	}
+
+	if na.outbox != nil && na.outbox.Holds(dest.Name) {
+		na.outbox.Add(dest, digest.Region.Code, messages, time.Now())
+		result.queued = len(messages)
+		return result, errDestinationBacklog
+	}
+
+	var lastErr error
+	for i, message := range messages {
+		// Print to console
+		fmt.Printf("\n=== FORMATTED MESSAGE (%s -> %s) ===\n", digest.Region.Name, dest.Name)
+		fmt.Println(string(message.Body))
+		fmt.Println("\n=== END OF MESSAGE ===")
+
+		// Send to webhook
+		err := na.SendToWebhook(ctx, dest, message)
+		switch {
+		case err == nil:
+			result.sent++
+		case na.outbox != nil && (ctx.Err() != nil || retryableDelivery(err)):
+			na.outbox.Add(dest, digest.Region.Code, messages[i:], time.Now())
+			result.queued += len(messages) - i
+			return result, err
+		case ctx.Err() != nil:
+			return result, err
+		default:
+			log.Printf("[%s] Message %d of %d to %s was rejected: %v", digest.Region.Code, i+1, len(messages), dest.Name, err)
+			result.failed++
+			lastErr = err
+		}
+	}
+	return result, lastErr
 }
 
+// exitInterrupted is the exit status of a run cancelled by a signal, the one shells use for SIGINT,
+// so schedulers can tell it from a run that finished
+const exitInterrupted = 130
+
 func main() {
+	explain := flag.Bool("explain", false, "print the per-signal score breakdown of every ranked item")
+	flag.Parse()
+
 	godotenv.Load()
 
-	webhookURL := os.Getenv("WEBHOOK_URL")
-	if webhookURL == "" {
-		log.Fatalf("WEBHOOK_URL environment variable is not set")
+	config, err := LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config.Explain = *explain
+
+	if flag.Arg(0) == "history" {
+		if err := runHistoryCommand(config, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		config.WebhookURL = webhookURL
 	}
 
-	deeplAPIKey := os.Getenv("DEEPL_API_KEY")
-	if deeplAPIKey == "" {
+	config.DeepLAPIKey = os.Getenv("DEEPL_API_KEY")
+	if config.DeepLAPIKey == "" && config.Translation.Provider == ProviderDeepL {
 		log.Fatalf("DEEPL_API_KEY environment variable is not set")
 	}
+	config.LibreTranslateAPIKey = os.Getenv("LIBRETRANSLATE_API_KEY")
+
+	catalog, err := LoadCatalog(os.Getenv("SOURCES_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	regions, err := LoadRegions(os.Getenv("REGIONS_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	regions, err = EnabledRegions(regions, catalog)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Destinations, err = ResolveDestinations(config, regions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var history *History
+	if config.HistoryFile != "" {
+		history, err = OpenHistory(config.HistoryFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var cache *TranslationCache
+	if config.Translation.CacheFile != "" {
+		cache, err = OpenTranslationCache(config.Translation.CacheFile, config.Translation.CacheTTL.Duration)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var outbox *Outbox
+	if config.Delivery.OutboxFile != "" {
+		outbox, err = OpenOutbox(config.Delivery.OutboxFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Cancel the run on Ctrl+C or when the scheduler stops the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Create and run aggregator
-	aggregator := NewNewsAggregator(webhookURL, deeplAPIKey)
+	aggregator := NewNewsAggregator(config, catalog, regions, history, cache, outbox)
 
-	if err := aggregator.Run(); err != nil {
+	err = aggregator.Run(ctx)
+	if ctx.Err() != nil {
+		// Also when every message went out before the signal arrived
+		log.Printf("Run interrupted, shutting down: %v", ctx.Err())
+		stop()
+		os.Exit(exitInterrupted)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Println("News aggregation with Russian translation completed successfully!")
+	log.Println("News aggregation with translation completed successfully!")
 }
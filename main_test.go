@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliverCountsMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		status map[string]int // Status per message language, 200 when unset
+		want   deliveryResult
+		failed bool
+	}{
+		{"all sent", nil, deliveryResult{sent: 2}, false},
+		{"second rejected", map[string]int{"en": http.StatusBadRequest}, deliveryResult{sent: 1, failed: 1}, true},
+		{"first rejected", map[string]int{"ru": http.StatusBadRequest}, deliveryResult{sent: 1, failed: 1}, true},
+		{"second queued", map[string]int{"en": http.StatusServiceUnavailable}, deliveryResult{sent: 1, queued: 1}, true},
+		{"first queued with the rest", map[string]int{"ru": http.StatusServiceUnavailable}, deliveryResult{queued: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				for lang, status := range tt.status {
+					if strings.Contains(string(body), "title-"+lang) {
+						w.WriteHeader(status)
+						return
+					}
+				}
+			}))
+			defer srv.Close()
+
+			config := DefaultConfig()
+			config.Languages = []string{"ru", "en"}
+			config.Delivery.Attempts = 1
+			outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			na := &NewsAggregator{config: config, client: srv.Client(), outbox: outbox}
+
+			digest := Digest{Region: RegionProfile{Code: "es", Header: "TOP"}, News: []NewsItem{{
+				Title: "Titular", Link: "https://elpais.com/a", Source: "El País", PublishDate: time.Now(),
+				Translations: map[string]Translation{
+					"ru": {Title: "title-ru", Status: TranslationDone},
+					"en": {Title: "title-en", Status: TranslationDone},
+				},
+			}}}
+			dest := Destination{Name: "test", URL: srv.URL, Channel: ChannelGeneric, Languages: config.Languages}
+
+			got, err := na.deliver(context.Background(), digest, dest)
+			if got != tt.want {
+				t.Errorf("deliver = %+v, want %+v", got, tt.want)
+			}
+			if (err != nil) != tt.failed {
+				t.Errorf("deliver error = %v, want error: %v", err, tt.failed)
+			}
+			if outbox.Len() != tt.want.queued {
+				t.Errorf("outbox holds %d messages, want %d", outbox.Len(), tt.want.queued)
+			}
+		})
+	}
+}
+
+func TestDeliverQueuesTheRestWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "title-en") {
+			// The signal arrives while the second message is on its way
+			cancel()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	config := DefaultConfig()
+	config.Languages = []string{"ru", "en"}
+	outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	na := &NewsAggregator{config: config, client: srv.Client(), outbox: outbox}
+	digest := Digest{Region: RegionProfile{Code: "es", Header: "TOP"}, News: []NewsItem{{
+		Title: "Titular", Link: "https://elpais.com/a", Source: "El País", PublishDate: time.Now(),
+		Translations: map[string]Translation{
+			"ru": {Title: "title-ru", Status: TranslationDone},
+			"en": {Title: "title-en", Status: TranslationDone},
+		},
+	}}}
+	dest := Destination{Name: "test", URL: srv.URL, Channel: ChannelGeneric, Languages: config.Languages}
+
+	got, err := na.deliver(ctx, digest, dest)
+	if err == nil {
+		t.Error("deliver reported no error after the run was cancelled")
+	}
+	if want := (deliveryResult{sent: 1, queued: 1}); got != want {
+		t.Errorf("deliver = %+v, want %+v", got, want)
+	}
+	if outbox.Len() != 1 {
+		t.Errorf("outbox holds %d messages, want the unsent one", outbox.Len())
+	}
+
+	// A destination not reached before the signal gets the whole digest queued
+	got, _ = na.deliver(ctx, digest, Destination{Name: "later", URL: srv.URL, Channel: ChannelGeneric, Languages: config.Languages})
+	if want := (deliveryResult{queued: 2}); got != want {
+		t.Errorf("deliver after cancellation = %+v, want %+v", got, want)
+	}
+}
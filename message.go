@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Channels a webhook can post to, each with its own payload format
+const (
+	ChannelGeneric  = "generic"  // Plain text
+	ChannelTelegram = "telegram" // Bot API sendMessage
+	ChannelDiscord  = "discord"  // Embeds
+	ChannelSlack    = "slack"    // Block Kit
+	ChannelTeams    = "teams"    // Adaptive Card
+)
+
+// channelMessageLimits is the longest text message, in characters, each channel
+// accepts; 0 means no limit. Discord and Slack limits apply to the text content
+// only, their embeds and blocks are sized separately.
+var channelMessageLimits = map[string]int{
+	ChannelGeneric:  0,
+	ChannelTelegram: 4096,
+	ChannelDiscord:  2000,
+	ChannelSlack:    0,
+	ChannelTeams:    0,
+}
+
+// shorterDescriptionLens are tried in turn when a digest is over the length limit
+var shorterDescriptionLens = []int{100, 50}
+
+// maxDigestTrends is how many trending topics a digest lists
+const maxDigestTrends = 10
+
+// messageLimit returns the message length limit for a channel, 0 when there is none
+func (c Config) messageLimit(channel string) int {
+	if c.MaxMessageLength > 0 {
+		return c.MaxMessageLength
+	}
+	return channelMessageLimits[channel]
+}
+
+// digestView is what a message shows of a digest, independent of the payload format
+type digestView struct {
+	RegionCode   string
+	RegionName   string
+	Languages    []string // Languages of the message, the first one is the headline
+	Flag         string
+	Header       string    // The region's header, rendered with this view
+	Date         time.Time // When the digest was built
+	Count        int       // Number of news items
+	Items        []itemView
+	TrendsHeader string
+	Trends       []string
+	NewsSources  []string // Sources of the digest's stories
+	TrendSources []string // Trend pages that returned topics for the region
+	PreviewLen   int      // Characters of each description to show
+}
+
+// itemView is one news item of a digestView
+type itemView struct {
+	Number        int
+	Title         string   // Headline in the first language
+	OtherTitles   []string // Title in each further language
+	Source        string
+	Warnings      []string // Translation problems worth pointing out
+	Descriptions  []string // One per language, the first language first; empty when missing
+	RelatedTrends []string
+	AlsoCoveredBy []string
+	Link          string
+	PublishDate   time.Time // Zero when unknown
+}
+
+// digestDateLayout is how a digest shows when it was built
+const digestDateLayout = "January 2, 2006 - 15:04 MST"
+
+// textMarkup is how a text message marks up bold text and escapes the rest
+type textMarkup struct {
+	bold   func(string) string
+	escape func(string) string
+}
+
+// plainMarkup writes Markdown-style bold and no escaping
+var plainMarkup = textMarkup{
+	bold:   func(s string) string { return "**" + s + "**" },
+	escape: func(s string) string { return s },
+}
+
+// messageParts is a digest rendered in pieces that can be regrouped into several messages
+type messageParts struct {
+	header    string
+	items     []string
+	footer    string
+	continued string // Replaces the header on every further message
+}
+
+// join puts the parts back together as a single message
+func (p messageParts) join() string {
+	return p.header + strings.Join(p.items, "") + p.footer
+}
+
+// renderTextParts renders a digest's header, one block per news item and the
+// trends and sources footer from the digest template, with descriptions cut to
+// descriptionLen characters or left out when descriptionLen is 0
+func renderTextParts(t *template.Template, view digestView, m textMarkup, descriptionLen int) (messageParts, error) {
+	var parts messageParts
+
+	t, err := t.Clone()
+	if err != nil {
+		return parts, err
+	}
+	t.Funcs(template.FuncMap{"bold": m.bold, "esc": m.escape})
+
+	if parts.header, err = executeTemplate(t, "header", view); err != nil {
+		return parts, fmt.Errorf("error rendering digest header: %v", err)
+	}
+	for _, item := range view.Items {
+		block, err := executeTemplate(t, "item", itemData{itemView: item, DescriptionLen: descriptionLen, Digest: view})
+		if err != nil {
+			return parts, fmt.Errorf("error rendering digest item %d: %v", item.Number, err)
+		}
+		parts.items = append(parts.items, block)
+	}
+	if parts.footer, err = executeTemplate(t, "footer", view); err != nil {
+		return parts, fmt.Errorf("error rendering digest footer: %v", err)
+	}
+	if parts.continued, err = executeTemplate(t, "continued", view); err != nil {
+		return parts, fmt.Errorf("error rendering digest continuation header: %v", err)
+	}
+	return parts, nil
+}
+
+// fitText renders a digest as text within limit characters. Descriptions are
+// shortened first; if that is not enough the items are spread over several
+// messages, and any single part that is still too long is rendered again from
+// shorter texts, so markup and escapes are never cut.
+func fitText(t *template.Template, view digestView, m textMarkup, limit int) ([]string, error) {
+	parts, err := renderTextParts(t, view, m, view.PreviewLen)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || messageLength(parts.join()) <= limit {
+		return []string{parts.join()}, nil
+	}
+	descriptionLen := view.PreviewLen
+	for _, n := range shorterDescriptionLens {
+		if n >= view.PreviewLen {
+			continue
+		}
+		descriptionLen = n
+		if parts, err = renderTextParts(t, view, m, descriptionLen); err != nil {
+			return nil, err
+		}
+		if messageLength(parts.join()) <= limit {
+			return []string{parts.join()}, nil
+		}
+	}
+
+	// fit returns a part, rendered again from a shrunk view if it is over room characters
+	fit := func(part func(messageParts) string, room int) (string, error) {
+		if text := part(parts); messageLength(text) <= room {
+			return text, nil
+		}
+		for n := room; ; n /= 2 {
+			shrunk, err := renderTextParts(t, shrinkView(view, n), m, descriptionLen)
+			if err != nil {
+				return "", err
+			}
+			if text := part(shrunk); messageLength(text) <= room {
+				return text, nil
+			}
+			if n == 0 {
+				return "", fmt.Errorf("digest template output does not fit in %d characters", limit)
+			}
+		}
+	}
+	header, err := fit(func(p messageParts) string { return p.header }, limit)
+	if err != nil {
+		return nil, err
+	}
+	continued, err := fit(func(p messageParts) string { return p.continued }, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]func(messageParts) string, 0, len(parts.items)+1)
+	for i := range parts.items {
+		blocks = append(blocks, func(p messageParts) string { return p.items[i] })
+	}
+	blocks = append(blocks, func(p messageParts) string { return p.footer })
+
+	var messages []string
+	current, empty := header, true
+	for _, part := range blocks {
+		if !empty && messageLength(current)+messageLength(part(parts)) > limit {
+			messages = append(messages, strings.TrimRight(current, "\n"))
+			current, empty = continued, true
+		}
+		block, err := fit(part, limit-messageLength(current))
+		if err != nil {
+			return nil, err
+		}
+		current += block
+		empty = false
+	}
+	return append(messages, current), nil
+}
+
+// shrinkView cuts every text of a view to at most n characters and keeps only
+// as many list entries as fit in n characters, so parts rendered from it are
+// shorter. Links are kept whole.
+func shrinkView(view digestView, n int) digestView {
+	view.Header = truncateString(view.Header, n)
+	view.TrendsHeader = truncateString(view.TrendsHeader, n)
+	view.Trends = fitList(view.Trends, n)
+	view.NewsSources = fitList(view.NewsSources, n)
+	view.TrendSources = fitList(view.TrendSources, n)
+
+	items := make([]itemView, len(view.Items))
+	for i, item := range view.Items {
+		item.Title = truncateString(item.Title, n)
+		item.Source = truncateString(item.Source, n)
+		item.OtherTitles = truncateEach(item.OtherTitles, n)
+		item.Descriptions = truncateEach(item.Descriptions, n)
+		item.Warnings = fitList(item.Warnings, n)
+		item.RelatedTrends = fitList(item.RelatedTrends, n)
+		item.AlsoCoveredBy = fitList(item.AlsoCoveredBy, n)
+		items[i] = item
+	}
+	view.Items = items
+	return view
+}
+
+// truncateEach returns a copy of list with every entry cut to n characters
+func truncateEach(list []string, n int) []string {
+	cut := make([]string, len(list))
+	for i, s := range list {
+		cut[i] = truncateString(s, n)
+	}
+	return cut
+}
+
+// fitList returns the leading entries of list that are n characters long together
+func fitList(list []string, n int) []string {
+	total := 0
+	for i, s := range list {
+		if total += messageLength(s); total > n {
+			return list[:i]
+		}
+	}
+	return list
+}
+
+// messageLength counts the characters of a message the way chat limits do
+func messageLength(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// truncateString shortens s to at most maxLen characters, "..." included. It
+// cuts at the last space where possible and never inside a character or a
+// combined sequence such as an accented letter or a flag. Below the length of
+// the ellipsis the leading characters are returned without one.
+func truncateString(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+
+	const ellipsis = "..."
+	if maxLen < len(ellipsis) {
+		return string(runes[:graphemeCut(runes, max(maxLen, 0))])
+	}
+	cut := graphemeCut(runes, maxLen-len(ellipsis))
+
+	// Find last space before the cut to avoid cutting words
+	for i := cut - 1; i > 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + ellipsis
+}
+
+// graphemeCut moves a cut before runes[i] back to the nearest grapheme boundary
+func graphemeCut(runes []rune, i int) int {
+	for i > 0 && !graphemeBoundary(runes, i) {
+		i--
+	}
+	return i
+}
+
+// graphemeBoundary reports whether text can be cut before runes[i] without
+// separating a character from its combining marks, variation selectors, skin
+// tone modifiers, zero width joiner sequences or the other half of a flag
+func graphemeBoundary(runes []rune, i int) bool {
+	if i <= 0 || i >= len(runes) {
+		return true
+	}
+	r := runes[i]
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Variation_Selector):
+		return false
+	case r == '\u200d' || runes[i-1] == '\u200d':
+		return false
+	case r >= 0x1f3fb && r <= 0x1f3ff: // Emoji skin tone modifiers
+		return false
+	case isRegionalIndicator(r):
+		// Regional indicators pair up into flags, so count the ones before r
+		n := 0
+		for j := i - 1; j >= 0 && isRegionalIndicator(runes[j]); j-- {
+			n++
+		}
+		return n%2 == 0
+	}
+	return true
+}
+
+// isRegionalIndicator reports whether r is one of the letters that make up flag emoji
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
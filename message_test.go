@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		maxLen int
+		want   string
+	}{
+		{"short enough", "Madrid", 10, "Madrid"},
+		{"exact length", "Madrid", 6, "Madrid"},
+		{"cut at a space", "El Gobierno aprueba los presupuestos", 20, "El Gobierno..."},
+		{"cut inside a long word", "Supercalifragilístico", 10, "Superca..."},
+		{"cyrillic", "Правительство Испании одобрило бюджет", 25, "Правительство Испании..."},
+		{"precomposed accent", "Caf\u00e9 con leche", 7, "Caf\u00e9..."},
+		{"combining accent not split", "Cafe\u0301 con leche", 7, "Caf..."},
+		{"combining accent kept whole", "Cafe\u0301s", 8, "Cafe\u0301s"},
+		{"flag not split", "🇪🇸🇲🇽🇦🇷", 5, "🇪🇸..."},
+		{"emoji sequence not split", "ab👍🏽cd", 5, "ab..."},
+		{"zero width joiner sequence not split", "a👩‍💻bcdef", 6, "a..."},
+		{"ellipsis only", "Madrid", 3, "..."},
+		{"shorter than the ellipsis", "Madrid", 2, "Ma"},
+		{"one character", "Madrid", 1, "M"},
+		{"shorter than the ellipsis keeps graphemes", "🇪🇸🇲🇽", 1, ""},
+		{"zero", "Madrid", 0, ""},
+		{"negative", "Madrid", -5, ""},
+		{"empty", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateString(tt.s, tt.maxLen)
+			if got != tt.want {
+				t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.maxLen, got, tt.want)
+			}
+			if n := messageLength(got); n > max(tt.maxLen, 0) && tt.s != got {
+				t.Errorf("truncateString(%q, %d) is %d characters long", tt.s, tt.maxLen, n)
+			}
+		})
+	}
+}
+
+func TestGraphemeBoundary(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		i    int
+		want bool
+	}{
+		{"start", "abc", 0, true},
+		{"end", "abc", 3, true},
+		{"between letters", "abc", 1, true},
+		{"before a combining mark", "é", 1, false},
+		{"before a variation selector", "❤️", 1, false},
+		{"before a skin tone", "👍🏽", 1, false},
+		{"before a zero width joiner", "👩‍💻", 1, false},
+		{"after a zero width joiner", "👩‍💻", 2, false},
+		{"inside a flag", "🇪🇸", 1, false},
+		{"between flags", "🇪🇸🇲🇽", 2, true},
+		{"inside the second flag", "🇪🇸🇲🇽", 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphemeBoundary([]rune(tt.s), tt.i); got != tt.want {
+				t.Errorf("graphemeBoundary(%q, %d) = %v, want %v", tt.s, tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+// testDigestView builds a digest view of n items with long descriptions
+func testDigestView(n int) digestView {
+	view := digestView{
+		RegionCode:   "es",
+		Flag:         "🇪🇸",
+		Header:       "TOP SPAIN NEWS",
+		Date:         time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC),
+		Count:        n,
+		TrendsHeader: "TRENDING IN SPAIN",
+		Trends:       []string{"#Madrid", "Real Madrid"},
+		NewsSources:  []string{"El País", "BBC"},
+		TrendSources: []string{"Trends24"},
+		PreviewLen:   150,
+	}
+	for i := range n {
+		view.Items = append(view.Items, itemView{
+			Number:       i + 1,
+			Title:        "El Gobierno aprueba los presupuestos <generales> & más",
+			Source:       "El País",
+			Descriptions: []string{strings.Repeat("Una descripción bastante larga (con paréntesis) y puntos. ", 5)},
+			Link:         "https://elpais.com/espana/2026-10-16/noticia.html?a=1&b=2",
+		})
+	}
+	return view
+}
+
+func TestFitText(t *testing.T) {
+	tmpl, err := ParseDigestTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := renderTextParts(tmpl, testDigestView(5), plainMarkup, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullLen := messageLength(full.join())
+
+	tests := []struct {
+		name     string
+		items    int
+		limit    int
+		messages int
+	}{
+		{"no limit", 5, 0, 1},
+		{"fits", 5, fullLen, 1},
+		{"shorter descriptions", 5, fullLen - 100, 1},
+		{"split", 5, 700, 2},
+		{"split further", 5, 420, 4},
+		{"one item per message", 5, 300, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts, err := fitText(tmpl, testDigestView(tt.items), plainMarkup, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(texts) != tt.messages {
+				t.Errorf("got %d messages, want %d", len(texts), tt.messages)
+			}
+			all := strings.Join(texts, "\n")
+			for i, text := range texts {
+				if tt.limit > 0 && messageLength(text) > tt.limit {
+					t.Errorf("message %d is %d characters, over the limit of %d", i+1, messageLength(text), tt.limit)
+				}
+				if i > 0 && !strings.HasPrefix(text, "🇪🇸 **TOP SPAIN NEWS (continued)**") {
+					t.Errorf("message %d does not start with the continuation header:\n%s", i+1, text)
+				}
+			}
+			for n := 1; n <= tt.items; n++ {
+				if !strings.Contains(all, fmt.Sprintf("📰 **%d. El Gobierno", n)) {
+					t.Errorf("item %d is missing", n)
+				}
+			}
+			if links := strings.Count(all, "🔗 https://elpais.com/espana/2026-10-16/noticia.html?a=1&b=2\n"); links != tt.items {
+				t.Errorf("got %d whole links, want %d", links, tt.items)
+			}
+			if !strings.Contains(texts[len(texts)-1], "📊 Sources: El País, BBC") {
+				t.Error("the last message has no footer")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunMetrics are the counters of one run, written to metrics_file for monitoring
+type RunMetrics struct {
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	Translator  string            `json:"translator"`
+	Translation *TranslationUsage `json:"translation,omitempty"` // nil when the translator does not meter characters
+}
+
+// saveRunMetrics writes the counters of the run that started at started to
+// the metrics file, replacing the previous run's file atomically
+func (na *NewsAggregator) saveRunMetrics(started time.Time) error {
+	metrics := RunMetrics{StartedAt: started, FinishedAt: time.Now(), Translator: na.translator.Name()}
+	if na.usage != nil {
+		usage := na.usage.RunUsage()
+		metrics.Translation = &usage
+	}
+
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := na.config.MetricsFile
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return fmt.Errorf("error saving run metrics: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving run metrics: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving run metrics: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving run metrics: %v", err)
+	}
+	return nil
+}
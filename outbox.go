@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DeliveryConfig controls webhook retries and the outbox of undelivered messages
+type DeliveryConfig struct {
+	Attempts     int      `json:"attempts"`       // Tries per message within a run, including the first
+	BaseDelay    Duration `json:"base_delay"`     // Wait before the first retry, doubled on every further retry
+	MaxDelay     Duration `json:"max_delay"`      // Upper bound for a single wait, a longer Retry-After queues the message
+	OutboxFile   string   `json:"outbox_file"`    // Messages that still failed are kept here for the next run, empty disables it
+	OutboxMaxAge Duration `json:"outbox_max_age"` // Queued messages older than this are dropped
+}
+
+// validate checks that no wait or age is negative
+func (c DeliveryConfig) validate() error {
+	if c.BaseDelay.Duration < 0 || c.MaxDelay.Duration < 0 || c.OutboxMaxAge.Duration < 0 {
+		return fmt.Errorf("delivery base_delay, max_delay and outbox_max_age must not be negative")
+	}
+	return nil
+}
+
+// retryPolicy returns the retry policy for webhook requests
+func (c DeliveryConfig) retryPolicy() retryPolicy {
+	return retryPolicy{Attempts: max(c.Attempts, 1), BaseDelay: c.BaseDelay.Duration, MaxDelay: c.MaxDelay.Duration}
+}
+
+// webhookError is a webhook response with a non-2xx status
+type webhookError struct {
+	Status int
+	Body   string
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook returned status %d: %s", e.Status, e.Body)
+}
+
+// retryableDelivery reports whether a failed delivery may succeed later:
+// network errors, 429 and 5xx responses are, other rejections are not
+func retryableDelivery(err error) bool {
+	var werr *webhookError
+	if errors.As(err, &werr) {
+		return retryableStatus(werr.Status)
+	}
+	return true
+}
+
+// idempotencyKey identifies a message to a destination, so the receiver can
+// drop a message it already got from an earlier attempt or run
+func idempotencyKey(dest Destination, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(dest.Name))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// OutboxEntry is a message that could not be delivered yet
+type OutboxEntry struct {
+	Destination    string    `json:"destination"` // Destination name, its URL and headers are taken from the current config
+	Region         string    `json:"region"`
+	ContentType    string    `json:"content_type"`
+	Body           string    `json:"body"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	QueuedAt       time.Time `json:"queued_at"`
+	Attempts       int       `json:"attempts"` // Runs that tried to deliver it
+}
+
+// Outbox is the persistent queue of undelivered messages, kept as a JSON file
+type Outbox struct {
+	path    string
+	entries []OutboxEntry
+}
+
+// OpenOutbox loads the outbox from path. A missing file is an empty outbox.
+func OpenOutbox(path string) (*Outbox, error) {
+	o := &Outbox{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading outbox: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &o.entries); err != nil {
+		return nil, fmt.Errorf("error parsing outbox: %v", err)
+	}
+	return o, nil
+}
+
+// Add queues messages for a destination
+func (o *Outbox) Add(dest Destination, region string, messages []webhookMessage, at time.Time) {
+	for _, m := range messages {
+		o.entries = append(o.entries, OutboxEntry{
+			Destination:    dest.Name,
+			Region:         region,
+			ContentType:    m.ContentType,
+			Body:           string(m.Body),
+			IdempotencyKey: m.IdempotencyKey,
+			QueuedAt:       at,
+			Attempts:       1,
+		})
+	}
+}
+
+// Len returns the number of queued messages
+func (o *Outbox) Len() int {
+	return len(o.entries)
+}
+
+// Holds reports whether messages to the named destination are queued
+func (o *Outbox) Holds(destination string) bool {
+	return slices.ContainsFunc(o.entries, func(e OutboxEntry) bool { return e.Destination == destination })
+}
+
+// Save writes the outbox back to disk, replacing the file atomically
+func (o *Outbox) Save() error {
+	data, err := json.MarshalIndent(o.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".outbox-*")
+	if err != nil {
+		return fmt.Errorf("error saving outbox: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving outbox: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving outbox: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("error saving outbox: %v", err)
+	}
+	return nil
+}
+
+// drainOutbox sends the messages earlier runs could not deliver, oldest first.
+// Delivered messages leave the outbox, as do those too old, rejected outright
+// or addressed to a destination that no longer exists. Once a message to a
+// destination fails again, its later messages wait for the next run so their
+// order is kept, and deliver queues the new digests for it behind them.
+func (na *NewsAggregator) drainOutbox(ctx context.Context) {
+	if na.outbox == nil || na.outbox.Len() == 0 {
+		return
+	}
+
+	destinations := make(map[string]Destination)
+	for _, dest := range na.config.Destinations {
+		destinations[dest.Name] = dest
+	}
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	var kept []OutboxEntry
+	delivered, dropped := 0, 0
+	for _, e := range na.outbox.entries {
+		dest, ok := destinations[e.Destination]
+		switch {
+		case !ok:
+			log.Printf("Dropping queued message for unknown destination %s", e.Destination)
+			dropped++
+			continue
+		case na.config.Delivery.OutboxMaxAge.Duration > 0 && now.Sub(e.QueuedAt) > na.config.Delivery.OutboxMaxAge.Duration:
+			log.Printf("Dropping queued message for %s, queued at %s", e.Destination, e.QueuedAt.Format(time.RFC3339))
+			dropped++
+			continue
+		case blocked[e.Destination] || ctx.Err() != nil:
+			kept = append(kept, e)
+			continue
+		}
+
+		message := webhookMessage{ContentType: e.ContentType, Body: []byte(e.Body), IdempotencyKey: e.IdempotencyKey}
+		err := na.SendToWebhook(ctx, dest, message)
+		switch {
+		case err == nil:
+			delivered++
+		case retryableDelivery(err):
+			log.Printf("[%s] Queued message for %s failed again: %v", e.Region, e.Destination, err)
+			e.Attempts++
+			kept = append(kept, e)
+			blocked[e.Destination] = true
+		default:
+			log.Printf("[%s] Dropping queued message rejected by %s: %v", e.Region, e.Destination, err)
+			dropped++
+		}
+	}
+	na.outbox.entries = kept
+
+	log.Printf("Outbox: %d delivered, %d still queued, %d dropped", delivered, len(kept), dropped)
+}
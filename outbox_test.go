@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrainOutbox(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.URL.Path+" "+string(body))
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/down"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.Contains(string(body), "rejected"):
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	config := DefaultConfig()
+	config.Delivery.Attempts = 1
+	config.Destinations = []Destination{
+		{Name: "up", URL: srv.URL + "/up", Channel: ChannelGeneric},
+		{Name: "down", URL: srv.URL + "/down", Channel: ChannelGeneric},
+	}
+	outbox, err := OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	na := &NewsAggregator{config: config, client: srv.Client(), outbox: outbox}
+
+	now := time.Now()
+	queue := func(dest, body string, at time.Time) {
+		outbox.Add(Destination{Name: dest}, "es", []webhookMessage{{ContentType: "text/plain", Body: []byte(body)}}, at)
+	}
+	queue("up", "first", now)
+	queue("down", "fails again", now)
+	queue("up", "rejected", now)
+	queue("removed", "unknown destination", now)
+	queue("up", "too old", now.Add(-72*time.Hour))
+	queue("down", "waits behind", now)
+	queue("up", "second", now)
+
+	na.drainOutbox(context.Background())
+
+	wantReceived := []string{"/up first", "/down fails again", "/up rejected", "/up second"}
+	if !slices.Equal(received, wantReceived) {
+		t.Errorf("sent %q, want %q", received, wantReceived)
+	}
+	var kept []string
+	for _, e := range outbox.entries {
+		kept = append(kept, e.Body)
+	}
+	if want := []string{"fails again", "waits behind"}; !slices.Equal(kept, want) {
+		t.Errorf("outbox keeps %q, want %q", kept, want)
+	}
+	if outbox.entries[0].Attempts != 2 {
+		t.Errorf("the message that failed again has %d attempts, want 2", outbox.entries[0].Attempts)
+	}
+
+	// New digests for the blocked destination wait behind its queued messages
+	received = nil
+	digest := Digest{Region: RegionProfile{Code: "es", Header: "TOP"}, News: []NewsItem{{
+		Title: "Titular", Link: "https://elpais.com/a", Source: "El País", PublishDate: now,
+	}}}
+	for _, dest := range config.Destinations {
+		dest.Languages = []string{""}
+		result, err := na.deliver(context.Background(), digest, dest)
+		switch dest.Name {
+		case "up":
+			if err != nil || result.sent != 1 {
+				t.Errorf("deliver to up = %+v, %v, want it sent", result, err)
+			}
+		case "down":
+			if err != errDestinationBacklog || result.queued != 1 {
+				t.Errorf("deliver to down = %+v, %v, want it queued behind the backlog", result, err)
+			}
+		}
+	}
+	if len(received) != 1 || !strings.HasPrefix(received[0], "/up ") {
+		t.Errorf("sent %q, want only the message to up", received)
+	}
+	if outbox.Len() != 3 {
+		t.Errorf("outbox holds %d messages, want 3", outbox.Len())
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookMessage is one request body ready to post to a webhook
+type webhookMessage struct {
+	ContentType    string
+	Body           []byte
+	IdempotencyKey string // Sent as the Idempotency-Key header, set when the message is delivered
+}
+
+// outputAdapter turns a digest into the native payloads of one channel
+type outputAdapter interface {
+	render(view digestView) ([]webhookMessage, error)
+}
+
+// Telegram parse modes
+const (
+	TelegramHTML       = "HTML"
+	TelegramMarkdownV2 = "MarkdownV2"
+)
+
+// outputAdapter returns the adapter that renders digests for a channel
+func (na *NewsAggregator) outputAdapter(channel, webhookURL string) (outputAdapter, error) {
+	limit := na.config.messageLimit(channel)
+	switch channel {
+	case ChannelGeneric:
+		return plainAdapter{template: na.config.digestTemplate, limit: limit}, nil
+	case ChannelTelegram:
+		// The chat is taken from the bot URL, e.g. https://api.telegram.org/bot<token>/sendMessage?chat_id=-100123
+		u, err := url.Parse(webhookURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing Telegram webhook URL: %v", err)
+		}
+		chatID := u.Query().Get("chat_id")
+		if chatID == "" {
+			return nil, fmt.Errorf("telegram webhook URL has no chat_id parameter")
+		}
+		return telegramAdapter{template: na.config.digestTemplate, chatID: chatID, parseMode: na.config.TelegramParseMode, limit: limit}, nil
+	case ChannelDiscord:
+		return discordAdapter{}, nil
+	case ChannelSlack:
+		return slackAdapter{}, nil
+	case ChannelTeams:
+		return teamsAdapter{}, nil
+	}
+	return nil, fmt.Errorf("unknown channel %q", channel)
+}
+
+// jsonMessage marshals a payload into a JSON webhook message
+func jsonMessage(payload any) (webhookMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return webhookMessage{}, err
+	}
+	return webhookMessage{ContentType: "application/json", Body: body}, nil
+}
+
+// itemDetails lists an item's further titles, warnings, descriptions and
+// related stories as lines, with descriptions cut to descriptionLen characters
+// or left out when descriptionLen is 0
+func itemDetails(item itemView, descriptionLen int) []string {
+	var lines []string
+	for _, title := range item.OtherTitles {
+		lines = append(lines, "🌐 "+title)
+	}
+	for _, warning := range item.Warnings {
+		lines = append(lines, "⚠️ "+warning)
+	}
+	for j, description := range item.Descriptions {
+		if description == "" || descriptionLen == 0 {
+			continue
+		}
+		marker := "📝 "
+		if j > 0 {
+			marker = "🌐 "
+		}
+		lines = append(lines, marker+truncateString(description, descriptionLen))
+	}
+	if len(item.RelatedTrends) > 0 {
+		lines = append(lines, "🔥 Trending: "+strings.Join(item.RelatedTrends, ", "))
+	}
+	if len(item.AlsoCoveredBy) > 0 {
+		lines = append(lines, "🗞 Also covered by: "+strings.Join(item.AlsoCoveredBy, ", "))
+	}
+	return lines
+}
+
+// itemByline is the source of an item followed by its publish time when known
+func itemByline(item itemView) string {
+	if item.PublishDate.IsZero() {
+		return "📍 " + item.Source
+	}
+	return "📍 " + item.Source + " · " + item.PublishDate.Local().Format("Jan 2, 15:04")
+}
+
+// footerLine names the sources a digest was built from
+func footerLine(view digestView) string {
+	return fmt.Sprintf("📊 Sources: %s | 🔍 Trends: %s", strings.Join(view.NewsSources, ", "), strings.Join(view.TrendSources, ", "))
+}
+
+// trendLines lists the trending topics as bullets
+func trendLines(view digestView) string {
+	if len(view.Trends) == 0 {
+		return "No trending topics available at this time."
+	}
+	return "• " + strings.Join(view.Trends, "\n• ")
+}
+
+// plainAdapter posts the digest as text/plain
+type plainAdapter struct {
+	template *template.Template
+	limit    int
+}
+
+func (a plainAdapter) render(view digestView) ([]webhookMessage, error) {
+	texts, err := fitText(a.template, view, plainMarkup, a.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []webhookMessage
+	for _, text := range texts {
+		messages = append(messages, webhookMessage{ContentType: "text/plain; charset=utf-8", Body: []byte(text)})
+	}
+	return messages, nil
+}
+
+// telegramAdapter posts Bot API sendMessage requests
+type telegramAdapter struct {
+	template  *template.Template
+	chatID    string
+	parseMode string
+	limit     int
+}
+
+// telegramMessage is the body of a sendMessage request
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+// telegramHTMLMarkup writes Telegram HTML
+var telegramHTMLMarkup = textMarkup{
+	bold:   func(s string) string { return "<b>" + s + "</b>" },
+	escape: html.EscapeString,
+}
+
+// markdownV2Special are the characters MarkdownV2 requires to be escaped
+var markdownV2Special = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// telegramMarkdownV2Markup writes Telegram MarkdownV2
+var telegramMarkdownV2Markup = textMarkup{
+	bold:   func(s string) string { return "*" + s + "*" },
+	escape: markdownV2Special.Replace,
+}
+
+func (a telegramAdapter) render(view digestView) ([]webhookMessage, error) {
+	markup := telegramHTMLMarkup
+	if a.parseMode == TelegramMarkdownV2 {
+		markup = telegramMarkdownV2Markup
+	}
+
+	texts, err := fitText(a.template, view, markup, a.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []webhookMessage
+	for _, text := range texts {
+		message, err := jsonMessage(telegramMessage{
+			ChatID:                a.chatID,
+			Text:                  text,
+			ParseMode:             a.parseMode,
+			DisableWebPagePreview: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Discord limits for message content and embeds
+const (
+	discordMaxContent     = 2000
+	discordMaxEmbeds      = 10
+	discordMaxEmbedsChars = 6000
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+)
+
+// discordAdapter posts webhook messages with one embed per news item
+type discordAdapter struct{}
+
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// size counts the characters Discord holds against the per-message embed limit
+func (e discordEmbed) size() int {
+	n := messageLength(e.Title) + messageLength(e.Description)
+	if e.Footer != nil {
+		n += messageLength(e.Footer.Text)
+	}
+	return n
+}
+
+func (a discordAdapter) render(view digestView) ([]webhookMessage, error) {
+	var embeds []discordEmbed
+	for _, item := range view.Items {
+		embed := discordEmbed{
+			Title:       truncateString(fmt.Sprintf("%d. %s", item.Number, item.Title), discordMaxTitle),
+			URL:         item.Link,
+			Description: truncateString(strings.Join(itemDetails(item, view.PreviewLen), "\n"), discordMaxDescription),
+			Footer:      &discordFooter{Text: item.Source},
+		}
+		if !item.PublishDate.IsZero() {
+			embed.Timestamp = item.PublishDate.UTC().Format(time.RFC3339)
+		}
+		embeds = append(embeds, embed)
+	}
+	embeds = append(embeds, discordEmbed{
+		Title:       truncateString("🔥 "+view.TrendsHeader, discordMaxTitle),
+		Description: truncateString(trendLines(view), discordMaxDescription),
+		Timestamp:   view.Date.UTC().Format(time.RFC3339),
+		Footer:      &discordFooter{Text: footerLine(view)},
+	})
+
+	// Spread the embeds over as many messages as the per-message limits need
+	var messages []webhookMessage
+	// The header is cut rather than the markup so the bold text stays closed
+	headerLen := discordMaxContent - 2*messageLength(view.Flag) - len("  **** ")
+	current := discordMessage{Content: fmt.Sprintf("%s **%s** %s", view.Flag, truncateString(view.Header, headerLen), view.Flag)}
+	size := 0
+	for _, embed := range embeds {
+		if len(current.Embeds) > 0 && (len(current.Embeds) == discordMaxEmbeds || size+embed.size() > discordMaxEmbedsChars) {
+			message, err := jsonMessage(current)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+			current, size = discordMessage{}, 0
+		}
+		current.Embeds = append(current.Embeds, embed)
+		size += embed.size()
+	}
+	message, err := jsonMessage(current)
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, message), nil
+}
+
+// Slack limits for Block Kit
+const (
+	slackMaxBlocks      = 50
+	slackMaxSectionText = 3000
+	slackMaxHeaderText  = 150
+)
+
+// slackAdapter posts Block Kit messages to an incoming webhook
+type slackAdapter struct{}
+
+type slackMessage struct {
+	Text   string       `json:"text"` // Shown in notifications
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackEscape escapes the characters Slack's mrkdwn treats as control characters
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+// slackLink writes a <link|text> link. mrkdwn has no escape for the "|" that
+// ends the URL, so it is percent-encoded in the link and replaced by a look-alike in the text.
+func slackLink(link, text string) string {
+	return "<" + slackEscape(strings.ReplaceAll(link, "|", "%7C")) + "|" + slackEscape(strings.ReplaceAll(text, "|", "∣")) + ">"
+}
+
+func slackSection(text string) slackBlock {
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncateString(text, slackMaxSectionText)}}
+}
+
+func slackContext(text string) slackBlock {
+	return slackBlock{Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: text}}}
+}
+
+func (a slackAdapter) render(view digestView) ([]webhookMessage, error) {
+	header := fmt.Sprintf("%s %s %s", view.Flag, view.Header, view.Flag)
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncateString(header, slackMaxHeaderText)}},
+		slackContext("📅 " + slackEscape(view.Date.Format(digestDateLayout))),
+		{Type: "divider"},
+	}
+
+	for _, item := range view.Items {
+		lines := []string{fmt.Sprintf("*%d. %s*", item.Number, slackLink(item.Link, item.Title))}
+		for _, line := range itemDetails(item, view.PreviewLen) {
+			lines = append(lines, slackEscape(line))
+		}
+		blocks = append(blocks, slackSection(strings.Join(lines, "\n")), slackContext(slackEscape(itemByline(item))))
+	}
+
+	blocks = append(blocks,
+		slackBlock{Type: "divider"},
+		slackSection(fmt.Sprintf("*🔥 %s 🔥*\n%s", slackEscape(view.TrendsHeader), slackEscape(trendLines(view)))),
+		slackContext(slackEscape(footerLine(view))),
+	)
+
+	var messages []webhookMessage
+	for start := 0; start < len(blocks); start += slackMaxBlocks {
+		message, err := jsonMessage(slackMessage{Text: header, Blocks: blocks[start:min(start+slackMaxBlocks, len(blocks))]})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// teamsAdapter posts an Adaptive Card to a Teams workflow or incoming webhook
+type teamsAdapter struct{}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string          `json:"$schema"`
+	Type    string          `json:"type"`
+	Version string          `json:"version"`
+	Body    []adaptiveBlock `json:"body"`
+}
+
+type adaptiveBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Size      string          `json:"size,omitempty"`
+	Weight    string          `json:"weight,omitempty"`
+	IsSubtle  bool            `json:"isSubtle,omitempty"`
+	Wrap      bool            `json:"wrap,omitempty"`
+	Separator bool            `json:"separator,omitempty"`
+	Items     []adaptiveBlock `json:"items,omitempty"`
+}
+
+// teamsLinkText escapes the text of a Markdown link so brackets in a title do not end it
+var teamsLinkText = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace
+
+// teamsLinkURL encodes the characters that would end the URL of a Markdown link
+var teamsLinkURL = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace
+
+func textBlock(text string) adaptiveBlock {
+	return adaptiveBlock{Type: "TextBlock", Text: text, Wrap: true}
+}
+
+func (a teamsAdapter) render(view digestView) ([]webhookMessage, error) {
+	header := textBlock(fmt.Sprintf("%s %s %s", view.Flag, view.Header, view.Flag))
+	header.Size, header.Weight = "Large", "Bolder"
+	date := textBlock("📅 " + view.Date.Format(digestDateLayout))
+	date.IsSubtle = true
+	body := []adaptiveBlock{header, date}
+
+	for _, item := range view.Items {
+		title := textBlock(fmt.Sprintf("%d. [%s](%s)", item.Number, teamsLinkText(item.Title), teamsLinkURL(item.Link)))
+		title.Weight = "Bolder"
+		byline := textBlock(itemByline(item))
+		byline.IsSubtle = true
+
+		container := adaptiveBlock{Type: "Container", Separator: true, Items: []adaptiveBlock{title, byline}}
+		if details := itemDetails(item, view.PreviewLen); len(details) > 0 {
+			// Adaptive Cards need a blank line between lines of a TextBlock
+			container.Items = append(container.Items, textBlock(strings.Join(details, "\n\n")))
+		}
+		body = append(body, container)
+	}
+
+	trendsHeader := textBlock("🔥 " + view.TrendsHeader + " 🔥")
+	trendsHeader.Weight = "Bolder"
+	trends := textBlock(trendLines(view))
+	if len(view.Trends) > 0 {
+		trends = textBlock("- " + strings.Join(view.Trends, "\n- "))
+	}
+	footer := textBlock(footerLine(view))
+	footer.IsSubtle = true
+	body = append(body, adaptiveBlock{Type: "Container", Separator: true, Items: []adaptiveBlock{trendsHeader, trends, footer}})
+
+	message, err := jsonMessage(teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: adaptiveCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []webhookMessage{message}, nil
+}
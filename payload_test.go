@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTelegramEscaping(t *testing.T) {
+	tests := []struct {
+		name     string
+		markup   textMarkup
+		in, want string
+	}{
+		{"html plain", telegramHTMLMarkup, "Madrid", "Madrid"},
+		{"html tags", telegramHTMLMarkup, "<b>Madrid</b>", "&lt;b&gt;Madrid&lt;/b&gt;"},
+		{"html ampersand", telegramHTMLMarkup, "Pérez & Sánchez", "Pérez &amp; Sánchez"},
+		{"html quotes", telegramHTMLMarkup, `"Sí" y 'no'`, "&#34;Sí&#34; y &#39;no&#39;"},
+		{"html markdown left alone", telegramHTMLMarkup, "*1.* [a](b)", "*1.* [a](b)"},
+		{"markdown plain", telegramMarkdownV2Markup, "Madrid", "Madrid"},
+		{"markdown punctuation", telegramMarkdownV2Markup, "Sube el IPC (2,3%).", `Sube el IPC \(2,3%\)\.`},
+		{"markdown formatting", telegramMarkdownV2Markup, "*bold* _it_ ~s~ `c`", "\\*bold\\* \\_it\\_ \\~s\\~ \\`c\\`"},
+		{"markdown link", telegramMarkdownV2Markup, "https://elpais.com/a-b?x=1#c", `https://elpais\.com/a\-b?x\=1\#c`},
+		{"markdown backslash", telegramMarkdownV2Markup, `a\b`, `a\\b`},
+		{"markdown all specials", telegramMarkdownV2Markup, `_*[]()~>#+-=|{}.!`, `\_\*\[\]\(\)\~\>\#\+\-\=\|\{\}\.\!`},
+		{"markdown html left alone", telegramMarkdownV2Markup, "<b>&amp;", `<b\>&amp;`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.markup.escape(tt.in); got != tt.want {
+				t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// htmlEntity matches the entities html.EscapeString writes
+var htmlEntity = regexp.MustCompile(`^&(amp|lt|gt|#34|#39);`)
+
+// checkTelegramHTML reports the first tag or entity in text that is cut or unbalanced
+func checkTelegramHTML(text string) string {
+	open := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], "<b>"):
+			if open {
+				return "nested <b>"
+			}
+			open = true
+			i += len("<b>") - 1
+		case strings.HasPrefix(text[i:], "</b>"):
+			if !open {
+				return "</b> without <b>"
+			}
+			open = false
+			i += len("</b>") - 1
+		case text[i] == '<' || text[i] == '>':
+			return "stray " + string(text[i])
+		case text[i] == '&':
+			if !htmlEntity.MatchString(text[i:]) {
+				return "broken entity at " + text[i:min(i+6, len(text))]
+			}
+		}
+	}
+	if open {
+		return "<b> not closed"
+	}
+	return ""
+}
+
+// checkTelegramMarkdownV2 reports the first unescaped special character, lone
+// backslash or unclosed bold in text
+func checkTelegramMarkdownV2(text string) string {
+	bold := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(text) || !strings.ContainsRune(`\_*[]()~`+"`"+`>#+-=|{}.!`, rune(text[i+1])) {
+				return "lone backslash"
+			}
+			i++
+		case c == '*':
+			bold = !bold
+		case strings.ContainsRune(`_[]()~`+"`"+`>#+-=|{}.!`, rune(c)):
+			return "unescaped " + string(c)
+		}
+	}
+	if bold {
+		return "bold not closed"
+	}
+	return ""
+}
+
+func TestFitTextKeepsTelegramMarkup(t *testing.T) {
+	tmpl, err := ParseDigestTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := testDigestView(3)
+	view.Header = "TOP SPAIN NEWS <hoy> & (mañana)."
+	view.Trends = []string{"#Madrid", "Pérez & Sánchez", "Real_Madrid-Barça"}
+	for i := range view.Items {
+		view.Items[i].OtherTitles = []string{"Правительство одобрило бюджет <общий> & (ещё)."}
+		view.Items[i].AlsoCoveredBy = []string{"El Mundo", "ABC", "20minutos.es"}
+	}
+
+	tests := []struct {
+		name   string
+		markup textMarkup
+		check  func(string) string
+	}{
+		{"html", telegramHTMLMarkup, checkTelegramHTML},
+		{"markdown v2", telegramMarkdownV2Markup, checkTelegramMarkdownV2},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{4096, 600, 300, 250} {
+			texts, err := fitText(tmpl, view, tt.markup, limit)
+			if err != nil {
+				t.Errorf("%s, limit %d: %v", tt.name, limit, err)
+				continue
+			}
+			for i, text := range texts {
+				if n := messageLength(text); n > limit {
+					t.Errorf("%s, limit %d: message %d is %d characters", tt.name, limit, i+1, n)
+				}
+				if problem := tt.check(text); problem != "" {
+					t.Errorf("%s, limit %d: message %d has %s:\n%s", tt.name, limit, i+1, problem, text)
+				}
+			}
+		}
+	}
+
+	if _, err := fitText(tmpl, view, telegramHTMLMarkup, 40); err == nil {
+		t.Error("fitText fitted a link into a message shorter than the link")
+	}
+}
+
+func TestLinkTitlesAreEscaped(t *testing.T) {
+	view := testDigestView(1)
+	view.Items[0].Title = "Sánchez | Feijóo: el debate [en directo] <hoy>"
+	view.Items[0].Link = "https://elpais.com/a_(b)|c"
+
+	tests := []struct {
+		name    string
+		adapter outputAdapter
+		want    string
+	}{
+		{"slack", slackAdapter{}, `<https://elpais.com/a_(b)%7Cc|Sánchez ∣ Feijóo: el debate [en directo] &lt;hoy&gt;>`},
+		{"teams", teamsAdapter{}, `[Sánchez | Feijóo: el debate \[en directo\] <hoy>](https://elpais.com/a_%28b%29|c)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := tt.adapter.render(view)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The payloads are JSON, so the wanted text is compared in its encoded form
+			want, err := json.Marshal(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = bytes.Trim(want, `"`)
+			if !bytes.Contains(messages[0].Body, want) {
+				t.Errorf("payload has no %s:\n%s", want, messages[0].Body)
+			}
+		})
+	}
+}
+
+func TestDiscordContentLimit(t *testing.T) {
+	view := testDigestView(1)
+	view.Header = strings.Repeat("NOTICIAS ", 300)
+	messages, err := discordAdapter{}.render(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message discordMessage
+	if err := json.Unmarshal(messages[0].Body, &message); err != nil {
+		t.Fatal(err)
+	}
+	if n := messageLength(message.Content); n > discordMaxContent {
+		t.Errorf("content is %d characters, over %d", n, discordMaxContent)
+	}
+	if !strings.HasPrefix(message.Content, "🇪🇸 **NOTICIAS") || !strings.HasSuffix(message.Content, "...** 🇪🇸") {
+		t.Errorf("content lost its flags or markup: %q", message.Content[len(message.Content)-20:])
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"text/template"
+)
+
+// defaultRegions is the built-in region list used when no REGIONS_FILE is given
+//
+//go:embed regions.json
+var defaultRegions []byte
+
+// TrendSpec describes a trending topics page and how to read it
+type TrendSpec struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Selectors []string `json:"selectors"` // Tried in order until one yields trends
+	MaxItems  int      `json:"max_items"`
+}
+
+// RegionProfile describes one digest: which sources feed it, how its items
+// are filtered and scored, how it is titled and where it is delivered
+type RegionProfile struct {
+	Code         string      `json:"code"`
+	Name         string      `json:"name"`
+	Enabled      bool        `json:"enabled"`
+	Flag         string      `json:"flag"`          // Emoji shown around the header
+	Header       string      `json:"header"`        // Digest title template, e.g. "TOP {{.Count}} SPAIN NEWS"
+	TrendsHeader string      `json:"trends_header"` // Title of the trending topics section
+	Keywords     []string    `json:"keywords"`      // Replaces scoring.keywords for this region
+	Sources      []string    `json:"sources"`       // Catalog source names, empty means every enabled source
+	Trends       []TrendSpec `json:"trends"`
+	WebhookURL   string      `json:"webhook_url"` // Defaults to WEBHOOK_URL
+	Channel      string      `json:"channel"`     // Payload format of the webhook, defaults to the config's channel
+}
+
+// trendOwner is the first region that declared a trend page
+type trendOwner struct {
+	region string
+	spec   TrendSpec
+}
+
+// equal reports whether two trend specs read the same page the same way
+func (t TrendSpec) equal(other TrendSpec) bool {
+	return t.Name == other.Name && t.URL == other.URL && t.MaxItems == other.MaxItems && slices.Equal(t.Selectors, other.Selectors)
+}
+
+// LoadRegions reads the region profiles from a JSON file, or the built-in ones if path is empty
+func LoadRegions(path string) ([]RegionProfile, error) {
+	data := defaultRegions
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading regions: %v", err)
+		}
+	}
+
+	var regions []RegionProfile
+	if err := json.Unmarshal(data, &regions); err != nil {
+		return nil, fmt.Errorf("error parsing regions: %v", err)
+	}
+
+	codes := make(map[string]bool)
+	trends := make(map[string]trendOwner)
+	for i, r := range regions {
+		if r.Code == "" {
+			return nil, fmt.Errorf("region entry %d has no code", i)
+		}
+		if codes[r.Code] {
+			return nil, fmt.Errorf("region %q is defined twice", r.Code)
+		}
+		codes[r.Code] = true
+		regions[i].Keywords = normalizeKeywords(r.Keywords)
+
+		if _, ok := channelMessageLimits[r.Channel]; r.Channel != "" && !ok {
+			return nil, fmt.Errorf("region %q has unknown channel %q", r.Code, r.Channel)
+		}
+
+		if _, err := template.New("").Funcs(templateFuncs).Parse(r.Header); err != nil {
+			return nil, fmt.Errorf("region %q has an invalid header: %v", r.Code, err)
+		}
+
+		for _, t := range r.Trends {
+			if t.Name == "" || t.URL == "" || len(t.Selectors) == 0 {
+				return nil, fmt.Errorf("region %q has a trends entry without name, url or selectors", r.Code)
+			}
+			// Trend pages are fetched once per name, so regions sharing one must agree on it
+			if other, ok := trends[t.Name]; ok && !other.spec.equal(t) {
+				return nil, fmt.Errorf("trends %q differ between regions %q and %q", t.Name, other.region, r.Code)
+			}
+			trends[t.Name] = trendOwner{region: r.Code, spec: t}
+		}
+	}
+
+	return regions, nil
+}
+
+// EnabledRegions returns the enabled profiles after checking that their
+// sources exist in the catalog and that no trend page shares a source's name
+func EnabledRegions(regions []RegionProfile, catalog []SourceSpec) ([]RegionProfile, error) {
+	names := make(map[string]bool)
+	for _, spec := range catalog {
+		if spec.Enabled {
+			names[spec.Name] = true
+		}
+	}
+
+	var enabled []RegionProfile
+	for _, r := range regions {
+		if !r.Enabled {
+			continue
+		}
+		for _, name := range r.Sources {
+			if !names[name] {
+				return nil, fmt.Errorf("region %q uses unknown or disabled source %q", r.Code, name)
+			}
+		}
+		for _, t := range r.Trends {
+			if names[t.Name] {
+				return nil, fmt.Errorf("region %q has trends named like the source %q", r.Code, t.Name)
+			}
+		}
+		enabled = append(enabled, r)
+	}
+
+	if len(enabled) == 0 {
+		return nil, fmt.Errorf("no region is enabled")
+	}
+	return enabled, nil
+}
+
+// usesSource reports whether the region reads the given news source
+func (r RegionProfile) usesSource(name string) bool {
+	return len(r.Sources) == 0 || slices.Contains(r.Sources, name)
+}
+
+// usesTrends reports whether the region reads the given trends source
+func (r RegionProfile) usesTrends(name string) bool {
+	for _, t := range r.Trends {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// channel returns the payload format of the region's webhook
+func (r RegionProfile) channel(defaultChannel string) string {
+	if r.Channel != "" {
+		return r.Channel
+	}
+	return defaultChannel
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRegions(t *testing.T) {
+	const trends = `{"name": "Trends24 Spain", "url": "https://trends24.in/spain/", "selectors": [".trend-link"], "max_items": 10}`
+	tests := []struct {
+		name    string
+		regions string
+		err     string
+	}{
+		{"valid", `[{"code": "es", "header": "TOP {{.Count}} SPAIN NEWS", "trends": [` + trends + `]}]`, ""},
+		{"shared trends", `[{"code": "es", "trends": [` + trends + `]}, {"code": "ca", "trends": [` + trends + `]}]`, ""},
+		{"no code", `[{"name": "Spain"}]`, "entry 0 has no code"},
+		{"duplicate code", `[{"code": "es"}, {"code": "es"}]`, `"es" is defined twice`},
+		{"unknown channel", `[{"code": "es", "channel": "fax"}]`, `unknown channel "fax"`},
+		{"invalid header", `[{"code": "es", "header": "TOP {{.Count"}]`, "invalid header"},
+		{"trends without selectors", `[{"code": "es", "trends": [{"name": "Trends24 Spain", "url": "https://trends24.in/spain/"}]}]`, "without name, url or selectors"},
+		{
+			"trends with another url",
+			`[{"code": "es", "trends": [` + trends + `]}, {"code": "mx", "trends": [{"name": "Trends24 Spain", "url": "https://trends24.in/mexico/", "selectors": [".trend-link"], "max_items": 10}]}]`,
+			`"Trends24 Spain" differ between regions "es" and "mx"`,
+		},
+		{
+			"trends with other selectors",
+			`[{"code": "es", "trends": [` + trends + `]}, {"code": "ca", "trends": [{"name": "Trends24 Spain", "url": "https://trends24.in/spain/", "selectors": ["a"], "max_items": 10}]}]`,
+			`"Trends24 Spain" differ between regions "es" and "ca"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "regions.json")
+			if err := os.WriteFile(path, []byte(tt.regions), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadRegions(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := LoadRegions(""); err != nil {
+		t.Errorf("built-in regions: %v", err)
+	}
+}
+
+func TestEnabledRegions(t *testing.T) {
+	catalog := []SourceSpec{
+		{Name: "El País", Enabled: true},
+		{Name: "ABC", Enabled: false},
+	}
+	trends := []TrendSpec{{Name: "Trends24 Spain", URL: "https://trends24.in/spain/", Selectors: []string{".trend-link"}}}
+
+	tests := []struct {
+		name    string
+		regions []RegionProfile
+		want    []string
+		err     string
+	}{
+		{"disabled left out", []RegionProfile{{Code: "es", Enabled: true, Trends: trends}, {Code: "mx"}}, []string{"es"}, ""},
+		{"all sources", []RegionProfile{{Code: "es", Enabled: true}, {Code: "ar", Enabled: true, Sources: []string{"El País"}}}, []string{"es", "ar"}, ""},
+		{"disabled region with bad source", []RegionProfile{{Code: "es", Enabled: true}, {Code: "mx", Sources: []string{"Milenio"}}}, []string{"es"}, ""},
+		{"unknown source", []RegionProfile{{Code: "es", Enabled: true, Sources: []string{"Milenio"}}}, nil, `unknown or disabled source "Milenio"`},
+		{"disabled source", []RegionProfile{{Code: "es", Enabled: true, Sources: []string{"ABC"}}}, nil, `unknown or disabled source "ABC"`},
+		{"trends named like a source", []RegionProfile{{Code: "es", Enabled: true, Trends: []TrendSpec{{Name: "El País"}}}}, nil, `named like the source "El País"`},
+		{"none enabled", []RegionProfile{{Code: "es"}}, nil, "no region is enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, err := EnabledRegions(tt.regions, catalog)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var codes []string
+			for _, r := range enabled {
+				codes = append(codes, r.Code)
+			}
+			if strings.Join(codes, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("enabled = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy says how often and how patiently a request is retried
+type retryPolicy struct {
+	Attempts  int           // Total attempts, including the first one
+	BaseDelay time.Duration // Wait before the first retry, doubled on every further retry
+	MaxDelay  time.Duration // Upper bound for a single wait; a longer Retry-After ends the retries
+}
+
+// translationRetry is used for calls to translation APIs
+var translationRetry = retryPolicy{Attempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// doWithRetry sends the request built by newRequest, retrying network errors,
+// 429 and 5xx responses with exponential backoff and jitter. A Retry-After
+// header, in seconds or as a date, replaces the computed wait; when it asks for
+// longer than MaxDelay the response is returned at once rather than retried
+// early. The last response or error is returned.
+func doWithRetry(ctx context.Context, client *http.Client, policy retryPolicy, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	delay := policy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= policy.Attempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		// Wait between half and all of the delay so that clients retrying together spread out
+		wait := min(delay/2+rand.N(delay/2+1), policy.MaxDelay)
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				if after > policy.MaxDelay {
+					return resp, nil
+				}
+				wait = after
+			}
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay = min(delay*2, policy.MaxDelay)
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
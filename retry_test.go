@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int  // Status of each attempt, the last one repeats
+		retryAfter string // Sent with every failing response
+		attempts   int
+		wantStatus int
+		wantTries  int32
+		minWait    time.Duration
+	}{
+		{"success", []int{200}, "", 3, 200, 1, 0},
+		{"recovers after 5xx", []int{503, 500, 200}, "", 4, 200, 3, 0},
+		{"gives up after the last attempt", []int{503}, "", 3, 503, 3, 0},
+		{"retries 429", []int{429, 200}, "", 3, 200, 2, 0},
+		{"4xx not retried", []int{400, 200}, "", 3, 400, 1, 0},
+		{"404 not retried", []int{404, 200}, "", 3, 404, 1, 0},
+		{"single attempt", []int{503, 200}, "", 1, 503, 1, 0},
+		{"honors Retry-After", []int{429, 200}, "1", 3, 200, 2, time.Second},
+		{"Retry-After over max delay returned at once", []int{429, 200}, "60", 3, 429, 1, 0},
+		{"Retry-After date over max delay", []int{503, 200}, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 3, 503, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tries atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(tries.Add(1))
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				if status != http.StatusOK && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			policy := retryPolicy{Attempts: tt.attempts, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+			start := time.Now()
+			resp, err := doWithRetry(context.Background(), srv.Client(), policy, func() (*http.Request, error) {
+				return http.NewRequest(http.MethodPost, srv.URL, nil)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if n := tries.Load(); n != tt.wantTries {
+				t.Errorf("got %d attempts, want %d", n, tt.wantTries)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", elapsed, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestDoWithRetryBackoff(t *testing.T) {
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := retryPolicy{Attempts: 4, BaseDelay: 40 * time.Millisecond, MaxDelay: 100 * time.Millisecond}
+	resp, err := doWithRetry(context.Background(), srv.Client(), policy, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, srv.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(times) != 4 {
+		t.Fatalf("got %d attempts, want 4", len(times))
+	}
+	// Each wait is between half and all of the delay, which doubles up to MaxDelay
+	bounds := []struct{ min, max time.Duration }{{20, 40}, {40, 80}, {50, 100}}
+	for i, b := range bounds {
+		wait := times[i+1].Sub(times[i])
+		if wait < b.min*time.Millisecond || wait > b.max*time.Millisecond+50*time.Millisecond {
+			t.Errorf("wait %d was %s, want %dms to %dms", i+1, wait, b.min, b.max)
+		}
+	}
+}
+
+func TestDoWithRetryNetworkErrorAndCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	tries := 0
+	newRequest := func() (*http.Request, error) {
+		tries++
+		return http.NewRequest(http.MethodGet, url, nil)
+	}
+	policy := retryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	if _, err := doWithRetry(context.Background(), http.DefaultClient, policy, newRequest); err == nil {
+		t.Error("doWithRetry reported no error for a closed server")
+	}
+	if tries != 3 {
+		t.Errorf("got %d attempts on a network error, want 3", tries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tries = 0
+	policy = retryPolicy{Attempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	if _, err := doWithRetry(ctx, http.DefaultClient, policy, newRequest); err == nil {
+		t.Error("doWithRetry reported no error for a cancelled context")
+	}
+	if tries != 1 {
+		t.Errorf("got %d attempts after cancellation, want 1", tries)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"30", 30 * time.Second, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ScoringConfig holds the weights of the relevance signals
+type ScoringConfig struct {
+	RecencyWeight      float64            `json:"recency_weight"`       // Points for an item published just now
+	RecencyHalfLife    Duration           `json:"recency_half_life"`    // Age at which the recency points are halved
+	Keywords           []string           `json:"keywords"`             // Spain keywords used for filtering and scoring
+	KeywordWeights     map[string]float64 `json:"keyword_weights"`      // Per-keyword multiplier, 1 when unset
+	KeywordWeight      float64            `json:"keyword_weight"`       // Points per keyword found in the title, description or full text
+	TitleKeywordWeight float64            `json:"title_keyword_weight"` // Extra points per keyword found in the title
+	SourceAuthority    map[string]float64 `json:"source_authority"`     // Points added to every item of a source
+	CoverageWeight     float64            `json:"coverage_weight"`      // Points per other outlet covering the same story
+	TrendWeight        float64            `json:"trend_weight"`         // Points per trending topic mentioned by the item
+	UnmatchedPenalty   float64            `json:"unmatched_penalty"`    // Points taken from score_only items that mention no keyword
+}
+
+// DefaultScoringConfig returns the weights used when the config file does not override them
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		RecencyWeight:   100,
+		RecencyHalfLife: Duration{4 * time.Hour},
+		Keywords: []string{
+			"españa", "spain", "español", "española",
+			"madrid", "barcelona", "valencia", "sevilla",
+			"gobierno español", "pedro sánchez", "rey felipe",
+			"la moncloa", "congreso de los diputados",
+		},
+		KeywordWeight:      10,
+		TitleKeywordWeight: 20,
+		CoverageWeight:     15,
+		TrendWeight:        25,
+		UnmatchedPenalty:   50,
+	}
+}
+
+// normalizeKeywords lower-cases and trims keywords, as they are looked for in lower-cased text
+func normalizeKeywords(keywords []string) []string {
+	normalized := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			normalized = append(normalized, keyword)
+		}
+	}
+	return normalized
+}
+
+// normalize brings the keywords and the keys of their weights to the form they are matched in
+func (c *ScoringConfig) normalize() {
+	c.Keywords = normalizeKeywords(c.Keywords)
+	if len(c.KeywordWeights) > 0 {
+		weights := make(map[string]float64, len(c.KeywordWeights))
+		for keyword, weight := range c.KeywordWeights {
+			weights[strings.ToLower(strings.TrimSpace(keyword))] = weight
+		}
+		c.KeywordWeights = weights
+	}
+}
+
+// SignalScore is the contribution of one signal to an item's score
+type SignalScore struct {
+	Signal string  `json:"signal"`
+	Points float64 `json:"points"`
+}
+
+// Signal is one ingredient of the relevance score
+type Signal interface {
+	Name() string
+	Score(item NewsItem) float64
+}
+
+// Scorer adds up the points of its signals
+type Scorer struct {
+	signals []Signal
+}
+
+// NewScorer builds the default signal set from the config and the trends of the current run
+func NewScorer(config ScoringConfig, trends *trendMatcher) *Scorer {
+	return &Scorer{signals: []Signal{
+		recencySignal{weight: config.RecencyWeight, halfLife: config.RecencyHalfLife.Duration},
+		keywordSignal{config: config},
+		authoritySignal{authority: config.SourceAuthority},
+		coverageSignal{weight: config.CoverageWeight},
+		trendSignal{weight: config.TrendWeight, trends: trends},
+		unmatchedSignal{penalty: config.UnmatchedPenalty},
+	}}
+}
+
+// Add appends a custom signal to the scorer
+func (s *Scorer) Add(signal Signal) {
+	s.signals = append(s.signals, signal)
+}
+
+// Score sets the score and per-signal breakdown on every item
+func (s *Scorer) Score(news []NewsItem) {
+	for i := range news {
+		total := 0.0
+		breakdown := make([]SignalScore, 0, len(s.signals))
+		for _, signal := range s.signals {
+			points := signal.Score(news[i])
+			total += points
+			breakdown = append(breakdown, SignalScore{Signal: signal.Name(), Points: points})
+		}
+		news[i].Score = int(math.Round(total))
+		news[i].ScoreBreakdown = breakdown
+	}
+}
+
+// recencySignal decays exponentially with the item's age. Items without a
+// known date get the points of an item one half-life old, so they are neither
+// pushed up nor buried.
+type recencySignal struct {
+	weight   float64
+	halfLife time.Duration
+}
+
+func (recencySignal) Name() string { return "recency" }
+
+func (s recencySignal) Score(item NewsItem) float64 {
+	if s.halfLife <= 0 {
+		return 0
+	}
+	if item.DateUnknown {
+		return s.weight / 2
+	}
+	age := max(time.Since(item.PublishDate), 0)
+	return s.weight * math.Pow(0.5, float64(age)/float64(s.halfLife))
+}
+
+// keywordSignal rewards keyword matches, counting title matches extra
+type keywordSignal struct {
+	config ScoringConfig
+}
+
+func (keywordSignal) Name() string { return "keywords" }
+
+func (s keywordSignal) Score(item NewsItem) float64 {
+	content := strings.ToLower(item.Title + " " + item.Description + " " + item.FullText)
+	title := strings.ToLower(item.Title)
+
+	points := 0.0
+	for _, keyword := range s.config.Keywords {
+		multiplier, ok := s.config.KeywordWeights[keyword]
+		if !ok {
+			multiplier = 1
+		}
+		if strings.Contains(content, keyword) {
+			points += s.config.KeywordWeight * multiplier
+		}
+		if strings.Contains(title, keyword) {
+			points += s.config.TitleKeywordWeight * multiplier
+		}
+	}
+	return points
+}
+
+// authoritySignal gives fixed points per source
+type authoritySignal struct {
+	authority map[string]float64
+}
+
+func (authoritySignal) Name() string { return "authority" }
+
+func (s authoritySignal) Score(item NewsItem) float64 {
+	return s.authority[item.Source]
+}
+
+// coverageSignal rewards stories reported by several outlets
+type coverageSignal struct {
+	weight float64
+}
+
+func (coverageSignal) Name() string { return "coverage" }
+
+func (s coverageSignal) Score(item NewsItem) float64 {
+	return s.weight * float64(len(item.AlsoCoveredBy))
+}
+
+// trendSignal rewards items that mention trending topics
+type trendSignal struct {
+	weight float64
+	trends *trendMatcher
+}
+
+func (trendSignal) Name() string { return "trends" }
+
+func (s trendSignal) Score(item NewsItem) float64 {
+	return s.weight * float64(len(s.trends.Match(item)))
+}
+
+// unmatchedSignal demotes items kept by a score_only source without mentioning Spain
+type unmatchedSignal struct {
+	penalty float64
+}
+
+func (unmatchedSignal) Name() string { return "unmatched" }
+
+func (s unmatchedSignal) Score(item NewsItem) float64 {
+	if item.Unmatched {
+		return -s.penalty
+	}
+	return 0
+}
+
+// writeScoreExplanation prints the per-signal breakdown of each ranked item
+func writeScoreExplanation(w io.Writer, news []NewsItem) {
+	fmt.Fprintln(w, "\n=== SCORE BREAKDOWN ===")
+	for i, item := range news {
+		fmt.Fprintf(w, "%3d. %4d  [%s] %s\n", i+1, item.Score, item.Source, truncateString(item.Title, 80))
+
+		parts := make([]string, 0, len(item.ScoreBreakdown))
+		for _, s := range item.ScoreBreakdown {
+			parts = append(parts, fmt.Sprintf("%s %.1f", s.Signal, s.Points))
+		}
+		fmt.Fprintf(w, "           %s\n", strings.Join(parts, " | "))
+	}
+	fmt.Fprintln(w, "=== END OF SCORE BREAKDOWN ===")
+}
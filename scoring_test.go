@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfiguredKeywordsMatchAnyCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"scoring": {"keywords": [" España ", "MADRID"], "keyword_weights": {"España": 2}, "keyword_weight": 10, "title_keyword_weight": 0}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title string
+		want  float64
+	}{
+		{"La economía de España crece", 20},
+		{"ESPAÑA y Madrid firman un acuerdo", 30},
+		{"Lluvias en el norte de Europa", 0},
+	}
+	signal := keywordSignal{config: config.Scoring}
+	for _, tt := range tests {
+		if got := signal.Score(NewsItem{Title: tt.title}); got != tt.want {
+			t.Errorf("Score(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+	if !matchesKeywords(NewsItem{Title: "Noticias de españa"}, config.Scoring.Keywords) {
+		t.Error("filter does not match a keyword configured with capitals")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "context"
+
+// SourceKind tells the aggregator what a source produces
+type SourceKind string
+
+const (
+	// SourceKindNews sources produce news items
+	SourceKindNews SourceKind = "news"
+	// SourceKindTrends sources produce trending topics
+	SourceKindTrends SourceKind = "trends"
+)
+
+// SourceResult holds whatever a source fetched during a run
+type SourceResult struct {
+	News   []NewsItem
+	Trends []string
+}
+
+// Source is a single outlet or trends provider the aggregator can pull from
+type Source interface {
+	Name() string
+	Kind() SourceKind
+	Fetch(ctx context.Context) (SourceResult, error)
+}
+
+// trendSourceFunc adapts a trends fetching function to the Source interface
+type trendSourceFunc struct {
+	name  string
+	fetch func(ctx context.Context) ([]string, error)
+}
+
+// TrendSource wraps a trends fetching function as a Source
+func TrendSource(name string, fetch func(ctx context.Context) ([]string, error)) Source {
+	return &trendSourceFunc{name: name, fetch: fetch}
+}
+
+func (s *trendSourceFunc) Name() string     { return s.name }
+func (s *trendSourceFunc) Kind() SourceKind { return SourceKindTrends }
+
+func (s *trendSourceFunc) Fetch(ctx context.Context) (SourceResult, error) {
+	trends, err := s.fetch(ctx)
+	return SourceResult{Trends: trends}, err
+}
+
+// SourceRegistry keeps the sources used by the aggregator in registration order
+type SourceRegistry struct {
+	sources []Source
+}
+
+// NewSourceRegistry creates an empty source registry
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{}
+}
+
+// Register adds a source to the registry
+func (r *SourceRegistry) Register(s Source) {
+	r.sources = append(r.sources, s)
+}
+
+// All returns every registered source
+func (r *SourceRegistry) All() []Source {
+	return r.sources
+}
+
+// Sources returns the registered sources of the given kind
+func (r *SourceRegistry) Sources(kind SourceKind) []Source {
+	var result []Source
+	for _, s := range r.sources {
+		if s.Kind() == kind {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// defaultDigestTemplate is the built-in layout of text digests
+//
+//go:embed digest.tmpl
+var defaultDigestTemplate string
+
+// digestTemplateNames are the templates a digest layout defines
+var digestTemplateNames = []string{"header", "item", "footer", "continued"}
+
+// templateFuncs are the helpers available to digest templates. bold and esc
+// are replaced with the markup of the channel when a digest is rendered.
+var templateFuncs = template.FuncMap{
+	"bold":     plainMarkup.bold,
+	"esc":      plainMarkup.escape,
+	"truncate": func(n int, s string) string { return truncateString(s, n) },
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// itemData is what the item template is given
+type itemData struct {
+	itemView
+	DescriptionLen int        // Characters of each description to show, 0 to leave them out
+	Digest         digestView // The digest the item belongs to
+}
+
+// ParseDigestTemplate returns the digest layout in the file at path, or the
+// built-in one when path is empty. The file replaces the built-in layout as a
+// whole, so it must define every template in digestTemplateNames.
+func ParseDigestTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		t, err := template.New("digest").Funcs(templateFuncs).Parse(defaultDigestTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing built-in digest template: %v", err)
+		}
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading digest template: %v", err)
+	}
+	t, err := template.New("digest").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing digest template: %v", err)
+	}
+	for _, name := range digestTemplateNames {
+		if t.Lookup(name) == nil {
+			return nil, fmt.Errorf("digest template does not define %q", name)
+		}
+	}
+	return t, nil
+}
+
+// executeTemplate renders one named template to a string
+func executeTemplate(t *template.Template, name string, data any) (string, error) {
+	var sb strings.Builder
+	if err := t.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// expandHeader renders a region header such as "TOP {{.Count}} SPAIN NEWS"
+// for a digest, falling back to the header as written if it is not a valid template
+func expandHeader(header string, view digestView) string {
+	t, err := template.New("region header").Funcs(templateFuncs).Parse(header)
+	if err != nil {
+		return header
+	}
+	expanded, err := executeTemplate(t, "region header", view)
+	if err != nil {
+		return header
+	}
+	return expanded
+}
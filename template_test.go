@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDigestTemplate(t *testing.T) {
+	full := `{{define "header"}}H{{end}}{{define "item"}}{{.Number}}. {{esc .Title}}{{end}}` +
+		`{{define "footer"}}F{{end}}{{define "continued"}}C{{end}}`
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"complete", full, ""},
+		{"missing item", `{{define "header"}}H{{end}}{{define "footer"}}F{{end}}{{define "continued"}}C{{end}}`, `does not define "item"`},
+		{"misspelt item", strings.Replace(full, `"item"`, `"itme"`, 1), `does not define "item"`},
+		{"no define blocks", "{{.Title}}", `does not define "header"`},
+		{"syntax error", `{{define "item"}}{{.Title}`, "error parsing digest template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "digest.tmpl")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tmpl, err := ParseDigestTemplate(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts, err := renderTextParts(tmpl, testDigestView(2), plainMarkup, 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parts.join(); !strings.HasPrefix(got, "H1. El Gobierno") || !strings.HasSuffix(got, "F") {
+				t.Errorf("rendered %q with the built-in layout mixed in", got)
+			}
+		})
+	}
+}
+
+func TestDigestSources(t *testing.T) {
+	sources := []string{"El País", "El Mundo", "ABC", "BBC"}
+	news := []NewsItem{
+		{Source: "BBC", AlsoCoveredBy: []string{"El País"}},
+		{Source: "BBC"},
+	}
+	if got, want := digestSources(sources, news), []string{"El País", "BBC"}; !slices.Equal(got, want) {
+		t.Errorf("digestSources = %q, want %q", got, want)
+	}
+	if got := digestSources(sources, nil); len(got) != 0 {
+		t.Errorf("digestSources without stories = %q, want none", got)
+	}
+}
+
+// goldenDigestView is the digest the golden files were rendered from
+func goldenDigestView() digestView {
+	return digestView{
+		Flag:         "🇪🇸",
+		Header:       "TOP 2 SPAIN NEWS",
+		Date:         time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC),
+		TrendsHeader: "TRENDING IN SPAIN",
+		Trends:       []string{"#EleccionesGenerales", "Real Madrid", "Pérez & Sánchez"},
+		NewsSources:  []string{"El País", "BBC"},
+		TrendSources: []string{"Trends24"},
+		Items: []itemView{
+			{
+				Number:        1,
+				Title:         "Правительство Испании одобрило бюджет (2027)",
+				OtherTitles:   []string{"Spain's government approves the 2027 budget"},
+				Source:        "El País",
+				Warnings:      []string{"Description not translated to EN"},
+				Descriptions:  []string{strings.Repeat("Бюджет включает рост расходов на оборону и жильё. ", 4), "El Consejo de Ministros aprueba las cuentas <generales> & más."},
+				RelatedTrends: []string{"#EleccionesGenerales"},
+				AlsoCoveredBy: []string{"BBC", "El Mundo"},
+				Link:          "https://elpais.com/espana/2026-10-16/presupuestos.html?utm=x&y=1",
+			},
+			{
+				Number:       2,
+				Title:        "Lluvias torrenciales en Valencia_Norte",
+				OtherTitles:  []string{"Torrential rain in northern Valencia"},
+				Source:       "BBC",
+				Descriptions: []string{"", ""},
+				Link:         "https://www.bbc.com/mundo/articles/c1",
+			},
+		},
+	}
+}
+
+// TestBuiltInTemplateMatchesGolden checks the built-in template against the
+// output of the hard-coded layout it replaced, kept in testdata
+func TestBuiltInTemplateMatchesGolden(t *testing.T) {
+	tmpl, err := ParseDigestTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	markups := []struct {
+		name      string
+		markup    textMarkup
+		continued string
+	}{
+		{"plain", plainMarkup, "🇪🇸 **TOP 2 SPAIN NEWS (continued)**\n\n"},
+		{"html", telegramHTMLMarkup, "🇪🇸 <b>TOP 2 SPAIN NEWS (continued)</b>\n\n"},
+		{"markdownv2", telegramMarkdownV2Markup, "🇪🇸 *TOP 2 SPAIN NEWS \\(continued\\)*\n\n"},
+	}
+	for _, m := range markups {
+		for _, variant := range []struct {
+			suffix         string
+			noTrends       bool
+			descriptionLen int
+		}{{"", false, 150}, {"_no_trends_short", true, 50}} {
+			name := "digest_" + m.name + variant.suffix + ".golden"
+			t.Run(name, func(t *testing.T) {
+				want, err := os.ReadFile(filepath.Join("testdata", name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				view := goldenDigestView()
+				if variant.noTrends {
+					view.Trends = nil
+				}
+				parts, err := renderTextParts(tmpl, view, m.markup, variant.descriptionLen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := parts.join(); got != string(want) {
+					t.Errorf("rendered digest differs from %s:\n%s", name, got)
+				}
+				if parts.continued != m.continued {
+					t.Errorf("continued = %q, want %q", parts.continued, m.continued)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+var (
+	// scriptOrStyle matches elements whose content is never readable text
+	scriptOrStyle = regexp.MustCompile(`(?is)<(?:script|style)\b.*?</(?:script|style)\s*>`)
+	// htmlTag matches any tag or comment
+	htmlTag = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+	// escapedTag matches the tags left after decoding entities; it needs a tag
+	// name so that a decoded "1 < 2" is kept
+	escapedTag = regexp.MustCompile(`(?s)<!--.*?-->|</?[a-zA-Z][^<>]*>`)
+	// boilerplate matches the "read more" links and truncation marks feeds append to summaries
+	boilerplate = regexp.MustCompile(`(?i)\b(?:seguir|sigue|continuar|continúa) leyendo\b\.*|\bleer más\b\.*|\bread more\b\.*|\[(?:…|\.\.\.)\]`)
+)
+
+// cleanText turns feed or page text into plain text: tags are stripped, entities
+// decoded, "read more" boilerplate removed and whitespace collapsed. Tags are
+// stripped again after decoding since some feeds escape their HTML twice
+func cleanText(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = scriptOrStyle.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	s = scriptOrStyle.ReplaceAllString(s, " ")
+	s = escapedTag.ReplaceAllString(s, " ")
+	s = boilerplate.ReplaceAllString(s, " ")
+	// strings.Fields also splits on non-breaking spaces
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// feedItemDescription returns the cleaned summary of a feed item, falling back
+// to its full content (content:encoded) and then its media description when
+// the summary is empty
+func feedItemDescription(item *gofeed.Item) string {
+	if description := cleanText(item.Description); description != "" {
+		return description
+	}
+	if content := cleanText(item.Content); content != "" {
+		return content
+	}
+	return cleanText(mediaDescription(item))
+}
+
+// mediaDescription finds a media:description, either directly on the item or
+// inside its media:group or media:content elements
+func mediaDescription(item *gofeed.Item) string {
+	media, ok := item.Extensions["media"]
+	if !ok {
+		return ""
+	}
+	if exts := media["description"]; len(exts) > 0 && exts[0].Value != "" {
+		return exts[0].Value
+	}
+	for _, parent := range []string{"group", "content"} {
+		for _, ext := range media[parent] {
+			if children := ext.Children["description"]; len(children) > 0 && children[0].Value != "" {
+				return children[0].Value
+			}
+		}
+	}
+	return ""
+}
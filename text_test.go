@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name     string
+		in, want string
+	}{
+		{"empty", "", ""},
+		{"plain", "El Gobierno aprueba los presupuestos", "El Gobierno aprueba los presupuestos"},
+		{"tags", "<p>El <b>Gobierno</b> aprueba</p>", "El Gobierno aprueba"},
+		{"comment", "Madrid<!-- <p>oculto</p> --> hoy", "Madrid hoy"},
+		{"script and style", "<script>var a = '<p>';</script><style>p {}</style>Texto", "Texto"},
+		{"entities", "Pérez &amp; Sánchez &quot;hoy&quot;", `Pérez & Sánchez "hoy"`},
+		{"escaped tags", "&lt;p&gt;Texto&lt;/p&gt;", "Texto"},
+		{"escaped link", `&lt;a href=&quot;https://elpais.com&quot;&gt;El País&lt;/a&gt; informa`, "El País informa"},
+		{"escaped script", "&lt;script&gt;alert(1)&lt;/script&gt;Texto", "Texto"},
+		{"decoded comparison kept", "La inflación: 2 &lt; 3 y 5 &gt; 4", "La inflación: 2 < 3 y 5 > 4"},
+		{"escaped twice stays text", "&amp;lt;p&amp;gt;", "&lt;p&gt;"},
+		{"read more", "El Gobierno aprueba los presupuestos. Seguir leyendo...", "El Gobierno aprueba los presupuestos."},
+		{"truncation mark", "El Gobierno aprueba [...]", "El Gobierno aprueba"},
+		{"whitespace", "  El\n\tGobierno  aprueba  ", "El Gobierno aprueba"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanText(tt.in); got != tt.want {
+				t.Errorf("cleanText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedItemDescription(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		want string
+	}{
+		{"description", `<description>&lt;p&gt;Resumen&lt;/p&gt;</description><content:encoded><![CDATA[<p>Contenido</p>]]></content:encoded>`, "Resumen"},
+		{"content encoded", `<description></description><content:encoded><![CDATA[<p>Contenido <b>completo</b></p>]]></content:encoded>`, "Contenido completo"},
+		{"description with only tags", `<description>&lt;img src="a.jpg"/&gt;</description><content:encoded><![CDATA[<p>Contenido</p>]]></content:encoded>`, "Contenido"},
+		{"media description", `<media:description>Descripción del vídeo</media:description>`, "Descripción del vídeo"},
+		{"media group", `<media:group><media:description type="html">&lt;p&gt;Galería&lt;/p&gt;</media:description></media:group>`, "Galería"},
+		{"media content", `<media:content url="https://elpais.com/v.mp4"><media:description>Vídeo</media:description></media:content>`, "Vídeo"},
+		{"nothing", `<link>https://elpais.com/a.html</link>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := gofeed.NewParser().ParseString(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:media="http://search.yahoo.com/mrss/">
+<channel><title>El País</title><item><title>Noticia</title>` + tt.item + `</item></channel></rss>`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := feedItemDescription(feed.Items[0]); got != tt.want {
+				t.Errorf("feedItemDescription = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// TranslationStatus tells how an item's translation into one language went
+type TranslationStatus string
+
+const (
+	// TranslationDone means the title and description were translated
+	TranslationDone TranslationStatus = "translated"
+	// TranslationOriginal means the item is already in the target language
+	TranslationOriginal TranslationStatus = "original"
+	// TranslationPartial means the title was translated but the description was not
+	TranslationPartial TranslationStatus = "partial"
+	// TranslationTitleFailed means the description was translated but the title was not
+	TranslationTitleFailed TranslationStatus = "title_failed"
+	// TranslationFailed means neither the title nor the description could be translated and the original text is shown
+	TranslationFailed TranslationStatus = "failed"
+)
+
+// Translation is a news item's title and description in one language
+type Translation struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Status      TranslationStatus `json:"status"`
+}
+
+// TranslationStatus returns how the item's translation into the given language went
+func (item NewsItem) TranslationStatus(lang string) TranslationStatus {
+	if t, ok := item.Translations[lang]; ok {
+		return t.Status
+	}
+	return TranslationOriginal
+}
+
+// TitleIn returns the item's title in the given language, or the original title when it was not translated
+func (item NewsItem) TitleIn(lang string) string {
+	if t, ok := item.Translations[lang]; ok && t.Title != "" {
+		return t.Title
+	}
+	return item.Title
+}
+
+// DescriptionIn returns the item's description in the given language, or the original one when it was not translated
+func (item NewsItem) DescriptionIn(lang string) string {
+	if t, ok := item.Translations[lang]; ok && t.Description != "" {
+		return t.Description
+	}
+	return item.Description
+}
+
+// Digest layouts for more than one target language
+const (
+	LayoutPerLanguage = "per_language"
+	LayoutBilingual   = "bilingual"
+)
+
+// Translation providers selectable in the config
+const (
+	ProviderDeepL          = "deepl"
+	ProviderLibreTranslate = "libretranslate"
+	ProviderNone           = "none"
+)
+
+// DeepL endpoints for the two subscription plans
+const (
+	deepLFreeURL = "https://api-free.deepl.com"
+	deepLProURL  = "https://api.deepl.com"
+)
+
+// TranslationConfig selects and configures the translation backend
+type TranslationConfig struct {
+	Provider          string   `json:"provider"`            // deepl, libretranslate or none
+	DeepLPlan         string   `json:"deepl_plan"`          // free or pro, picks the DeepL endpoint
+	DeepLURL          string   `json:"deepl_url"`           // Overrides the plan endpoint, e.g. for a local stand-in server
+	LibreTranslateURL string   `json:"libretranslate_url"`  // Base URL of a LibreTranslate instance
+	CacheFile         string   `json:"cache_file"`          // Persistent translation cache, empty disables it
+	CacheTTL          Duration `json:"cache_ttl"`           // Cached translations older than this are dropped, 0 keeps them forever
+	MaxDescriptionLen int      `json:"max_description_len"` // Descriptions are shortened to this many characters before translation, 0 keeps them whole
+	CharacterBudget   int      `json:"character_budget"`    // Most characters sent to DeepL in one run, 0 means no limit
+	QuotaReserve      int      `json:"quota_reserve"`       // DeepL quota characters to leave unused
+}
+
+// validate checks the provider and plan names
+func (c TranslationConfig) validate() error {
+	switch c.Provider {
+	case ProviderDeepL, ProviderLibreTranslate, ProviderNone:
+	default:
+		return fmt.Errorf("unknown translation provider %q", c.Provider)
+	}
+	if c.Provider == ProviderDeepL && c.DeepLPlan != "free" && c.DeepLPlan != "pro" {
+		return fmt.Errorf("deepl_plan must be \"free\" or \"pro\", got %q", c.DeepLPlan)
+	}
+	return nil
+}
+
+// translationAPIError is a translation API response with an error status
+type translationAPIError struct {
+	Provider string
+	Status   int
+	Body     string
+}
+
+func (e *translationAPIError) Error() string {
+	return fmt.Sprintf("%s API error: %d - %s", e.Provider, e.Status, e.Body)
+}
+
+// statusDeepLQuotaExceeded is DeepL's status for an account out of characters
+const statusDeepLQuotaExceeded = 456
+
+// batchTooLarge reports whether a batch was refused for its size or number of
+// texts, so that it may pass when split
+func batchTooLarge(err error) bool {
+	var apiErr *translationAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Status == http.StatusRequestEntityTooLarge ||
+		apiErr.Status == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Body), "too many")
+}
+
+// stopTranslating reports whether every further batch would fail the same
+// way: the budget is spent, the credentials or the account quota are refused,
+// or the run was cancelled
+func stopTranslating(err error) bool {
+	if errors.Is(err, errTranslationBudget) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr *translationAPIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusUnauthorized, http.StatusForbidden, statusDeepLQuotaExceeded:
+			return true
+		}
+	}
+	return false
+}
+
+// Translator translates a batch of texts. Languages are lower-case ISO 639-1
+// codes such as "es" or "ru"; the result has one entry per input text.
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error)
+}
+
+// languageDetector is implemented by translators that report the language
+// they detected for texts sent without a source language
+type languageDetector interface {
+	DetectedLanguage(text string) string
+}
+
+// NewTranslator builds the translator selected by the config
+func NewTranslator(config Config, client *http.Client) Translator {
+	switch config.Translation.Provider {
+	case ProviderDeepL:
+		endpoint := config.Translation.DeepLURL
+		if endpoint == "" {
+			endpoint = deepLFreeURL
+			if config.Translation.DeepLPlan == "pro" {
+				endpoint = deepLProURL
+			}
+		}
+		return &DeepLTranslator{
+			client:       client,
+			endpoint:     strings.TrimSuffix(endpoint, "/"),
+			apiKey:       config.DeepLAPIKey,
+			budget:       config.Translation.CharacterBudget,
+			quotaReserve: config.Translation.QuotaReserve,
+		}
+	case ProviderLibreTranslate:
+		return &LibreTranslator{
+			client:   client,
+			endpoint: strings.TrimSuffix(config.Translation.LibreTranslateURL, "/"),
+			apiKey:   config.LibreTranslateAPIKey,
+		}
+	default:
+		return PassthroughTranslator{}
+	}
+}
+
+// DeepLTranslation represents the DeepL API response
+type DeepLTranslation struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+// DeepLTranslator translates with the DeepL API, keeping within the run
+// budget and the account's remaining quota
+type DeepLTranslator struct {
+	client       *http.Client
+	endpoint     string
+	apiKey       string
+	budget       int
+	quotaReserve int
+
+	usageChecked bool
+	usage        *DeepLUsage // nil when the quota could not be checked
+	charsSent    int
+	charsRefused int
+	detected     map[string]string // Text -> language DeepL detected when it was sent without one
+}
+
+func (t *DeepLTranslator) Name() string { return ProviderDeepL }
+
+// Translate sends the texts to DeepL's /v2/translate endpoint
+func (t *DeepLTranslator) Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+
+	// A batch over the budget is trimmed to the texts that fit
+	n, chars, budgetErr := t.reserve(ctx, texts)
+	for _, text := range texts[n:] {
+		t.charsRefused += utf8.RuneCountInString(text)
+	}
+	if n == 0 {
+		return nil, budgetErr
+	}
+
+	translations, err := t.translate(ctx, texts[:n], sourceLang, targetLang)
+	if err != nil {
+		// Failed requests are not billed
+		t.charsSent -= chars
+		return nil, err
+	}
+	if n == len(texts) {
+		return translations, nil
+	}
+
+	partial := &partialTranslationError{Translations: append(translations, texts[n:]...), Done: make([]bool, len(texts)), Err: budgetErr}
+	for i := range n {
+		partial.Done[i] = true
+	}
+	return nil, partial
+}
+
+// translate makes the /v2/translate request
+func (t *DeepLTranslator) translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	// Prepare request body
+	data := make(map[string]interface{})
+	data["text"] = texts
+	data["target_lang"] = strings.ToUpper(targetLang)
+	if sourceLang != "" {
+		data["source_lang"] = strings.ToUpper(sourceLang)
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doWithRetry(ctx, t.client, translationRetry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint+"/v2/translate", bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "DeepL-Auth-Key "+t.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &translationAPIError{Provider: "DeepL", Status: resp.StatusCode, Body: string(body)}
+	}
+
+	var result DeepLTranslation
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(result.Translations))
+	for i, tr := range result.Translations {
+		translations[i] = tr.Text
+		if sourceLang == "" && i < len(texts) && tr.DetectedSourceLanguage != "" {
+			if t.detected == nil {
+				t.detected = make(map[string]string)
+			}
+			t.detected[texts[i]] = normalizeLangCode(tr.DetectedSourceLanguage)
+		}
+	}
+
+	return translations, nil
+}
+
+// DetectedLanguage returns the language DeepL detected for a text sent without a source language
+func (t *DeepLTranslator) DetectedLanguage(text string) string {
+	return t.detected[text]
+}
+
+// LibreTranslator translates with a LibreTranslate or compatible self-hosted server
+type LibreTranslator struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+func (t *LibreTranslator) Name() string { return ProviderLibreTranslate }
+
+// Translate sends the texts to the server's /translate endpoint as one batch
+func (t *LibreTranslator) Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+
+	if sourceLang == "" {
+		sourceLang = "auto"
+	}
+	data := map[string]interface{}{
+		"q":      texts,
+		"source": strings.ToLower(sourceLang),
+		"target": strings.ToLower(targetLang),
+		"format": "text",
+	}
+	if t.apiKey != "" {
+		data["api_key"] = t.apiKey
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doWithRetry(ctx, t.client, translationRetry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint+"/translate", bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &translationAPIError{Provider: "LibreTranslate", Status: resp.StatusCode, Body: string(body)}
+	}
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.TranslatedText, nil
+}
+
+// PassthroughTranslator returns the texts unchanged, for running without a translation backend
+type PassthroughTranslator struct{}
+
+func (PassthroughTranslator) Name() string { return ProviderNone }
+
+// Translate returns a copy of the texts
+func (PassthroughTranslator) Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	return append([]string{}, texts...), nil
+}
+
+// Limits on a single translation request. DeepL accepts at most 50 texts per
+// request and bounds the request size, so larger batches are split up front.
+const (
+	maxBatchTexts = 50
+	maxBatchBytes = 64 * 1024
+)
+
+// translateTexts translates texts in batches that fit the request limits.
+// ok[i] reports whether texts[i] was translated; failed texts keep their
+// original value. Once an error shows that further batches would fail too,
+// the remaining texts are left untranslated.
+func (na *NewsAggregator) translateTexts(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, []bool) {
+	translated := append([]string{}, texts...)
+	ok := make([]bool, len(texts))
+	if len(texts) == 0 {
+		return translated, ok
+	}
+
+	// Batch boundaries: batch b covers texts[bounds[b]:bounds[b+1]]
+	bounds := []int{0}
+	size := 0
+	for i, text := range texts {
+		if start := bounds[len(bounds)-1]; i > start && (i-start >= maxBatchTexts || size+len(text) > maxBatchBytes) {
+			bounds = append(bounds, i)
+			size = 0
+		}
+		size += len(text)
+	}
+	bounds = append(bounds, len(texts))
+
+	for b := 0; b+1 < len(bounds); b++ {
+		if err := na.translateBatch(ctx, texts, translated, ok, bounds[b], bounds[b+1], sourceLang, targetLang); err != nil {
+			log.Printf("Stopped translating from %q to %s: %v", sourceLang, targetLang, err)
+			break
+		}
+	}
+	return translated, ok
+}
+
+// translateBatch translates texts[from:to]. A batch refused as too large is
+// split in half and each half sent again; after any other failure the batch
+// stays untranslated. The error is returned when it means translating should
+// stop altogether, see stopTranslating.
+func (na *NewsAggregator) translateBatch(ctx context.Context, texts, translated []string, ok []bool, from, to int, sourceLang, targetLang string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	result, err := na.translator.Translate(ctx, texts[from:to], sourceLang, targetLang)
+	if err == nil && len(result) != to-from {
+		err = fmt.Errorf("got %d translations for %d texts", len(result), to-from)
+	}
+	if err == nil {
+		for i, text := range result {
+			translated[from+i] = text
+			ok[from+i] = true
+		}
+		return nil
+	}
+
+	// The budget ran out partway, keep what was translated
+	var partial *partialTranslationError
+	if errors.As(err, &partial) && len(partial.Done) == to-from {
+		for i, done := range partial.Done {
+			if done {
+				translated[from+i] = partial.Translations[i]
+				ok[from+i] = true
+			}
+		}
+	}
+
+	if to-from > 1 && batchTooLarge(err) {
+		log.Printf("A batch of %d texts from %q to %s is too large, splitting it: %v", to-from, sourceLang, targetLang, err)
+		mid := from + (to-from)/2
+		if err := na.translateBatch(ctx, texts, translated, ok, from, mid, sourceLang, targetLang); err != nil {
+			return err
+		}
+		return na.translateBatch(ctx, texts, translated, ok, mid, to, sourceLang, targetLang)
+	}
+
+	if stopTranslating(err) {
+		return err
+	}
+	log.Printf("Error translating a batch of %d texts from %q to %s: %v", to-from, sourceLang, targetLang, err)
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeTranslator upper-cases texts and fails batches as fail decides
+type fakeTranslator struct {
+	fail  func(texts []string) error
+	calls int
+}
+
+func (*fakeTranslator) Name() string { return "fake" }
+
+func (f *fakeTranslator) Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	f.calls++
+	if err := f.fail(texts); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, text := range texts {
+		out = append(out, strings.ToUpper(text))
+	}
+	return out, nil
+}
+
+func TestTranslateTextsFailures(t *testing.T) {
+	tooLarge := &translationAPIError{Provider: "DeepL", Status: http.StatusRequestEntityTooLarge}
+	tests := []struct {
+		name  string
+		fail  func(texts []string) error
+		texts int
+		ok    int // Texts translated
+		calls int // Requests made
+	}{
+		{"success", func([]string) error { return nil }, 8, 8, 1},
+		{"too large is split", func(texts []string) error {
+			if len(texts) > 2 {
+				return tooLarge
+			}
+			return nil
+		}, 8, 8, 7},
+		{"too many texts is split", func(texts []string) error {
+			if len(texts) > 4 {
+				return &translationAPIError{Provider: "DeepL", Status: http.StatusBadRequest, Body: "Too many texts"}
+			}
+			return nil
+		}, 8, 8, 3},
+		{"one bad text", func(texts []string) error {
+			if len(texts) > 1 && slices.Contains(texts, "t3") {
+				return tooLarge
+			}
+			if slices.Contains(texts, "t3") {
+				return &translationAPIError{Provider: "DeepL", Status: http.StatusBadRequest}
+			}
+			return nil
+		}, 8, 7, 7},
+		{"auth is not split", func([]string) error {
+			return &translationAPIError{Provider: "DeepL", Status: http.StatusForbidden}
+		}, 120, 0, 1},
+		{"quota is not split", func([]string) error {
+			return &translationAPIError{Provider: "DeepL", Status: statusDeepLQuotaExceeded}
+		}, 120, 0, 1},
+		{"budget stops", func([]string) error {
+			return fmt.Errorf("%w: 10 characters needed, 0 left", errTranslationBudget)
+		}, 120, 0, 1},
+		{"server error fails the batch only", func([]string) error {
+			return &translationAPIError{Provider: "DeepL", Status: http.StatusInternalServerError}
+		}, 120, 0, 3},
+		{"bad request fails the batch only", func(texts []string) error {
+			if texts[0] == "t0" {
+				return &translationAPIError{Provider: "DeepL", Status: http.StatusBadRequest}
+			}
+			return nil
+		}, 120, 70, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTranslator{fail: tt.fail}
+			na := &NewsAggregator{translator: fake}
+
+			var texts []string
+			for i := range tt.texts {
+				texts = append(texts, fmt.Sprintf("t%d", i))
+			}
+			translated, ok := na.translateTexts(context.Background(), texts, "es", "ru")
+
+			n := 0
+			for i := range texts {
+				switch {
+				case ok[i]:
+					n++
+					if translated[i] != strings.ToUpper(texts[i]) {
+						t.Errorf("text %d = %q, want its translation", i, translated[i])
+					}
+				case translated[i] != texts[i]:
+					t.Errorf("untranslated text %d = %q, want the original", i, translated[i])
+				}
+			}
+			if n != tt.ok {
+				t.Errorf("%d texts translated, want %d", n, tt.ok)
+			}
+			if fake.calls != tt.calls {
+				t.Errorf("%d requests, want %d", fake.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestTranslateTextsStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeTranslator{fail: func([]string) error {
+		cancel()
+		return context.Canceled
+	}}
+	na := &NewsAggregator{translator: fake}
+
+	texts := make([]string, 120)
+	if _, ok := na.translateTexts(ctx, texts, "es", "ru"); slices.Contains(ok, true) {
+		t.Error("texts translated after cancellation")
+	}
+	if fake.calls != 1 {
+		t.Errorf("%d requests, want 1", fake.calls)
+	}
+}
+
+func TestTranslateItemsStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		item        NewsItem
+		bad         []string // Texts the translator refuses
+		status      TranslationStatus
+		title       string
+		description string
+	}{
+		{"translated", NewsItem{Title: "titular", Description: "texto"}, nil, TranslationDone, "TITULAR", "TEXTO"},
+		{"no description", NewsItem{Title: "titular"}, nil, TranslationDone, "TITULAR", ""},
+		{"description failed", NewsItem{Title: "titular", Description: "texto"}, []string{"texto"}, TranslationPartial, "TITULAR", "texto"},
+		{"title failed", NewsItem{Title: "titular", Description: "texto"}, []string{"titular"}, TranslationTitleFailed, "titular", "TEXTO"},
+		{"title failed without description", NewsItem{Title: "titular"}, []string{"titular"}, TranslationFailed, "titular", ""},
+		{"both failed", NewsItem{Title: "titular", Description: "texto"}, []string{"titular", "texto"}, TranslationFailed, "titular", "texto"},
+		{"already in the language", NewsItem{Title: "titular", Description: "texto", Language: "ru"}, nil, TranslationOriginal, "titular", "texto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Batches are refused as too large so every text is tried on its own
+			fake := &fakeTranslator{fail: func(texts []string) error {
+				if len(texts) > 1 {
+					return &translationAPIError{Provider: "DeepL", Status: http.StatusRequestEntityTooLarge}
+				}
+				if slices.Contains(tt.bad, texts[0]) {
+					return &translationAPIError{Provider: "DeepL", Status: http.StatusBadRequest}
+				}
+				return nil
+			}}
+			na := &NewsAggregator{translator: fake}
+
+			news := []NewsItem{tt.item}
+			na.translateItems(context.Background(), news, "ru")
+
+			if got := news[0].TranslationStatus("ru"); got != tt.status {
+				t.Errorf("status = %s, want %s", got, tt.status)
+			}
+			if got := news[0].TitleIn("ru"); got != tt.title {
+				t.Errorf("title = %q, want %q", got, tt.title)
+			}
+			if got := news[0].DescriptionIn("ru"); got != tt.description {
+				t.Errorf("description = %q, want %q", got, tt.description)
+			}
+		})
+	}
+}
+
+func TestLibreTranslator(t *testing.T) {
+	type libreRequest struct {
+		Q      []string `json:"q"`
+		Source string   `json:"source"`
+		Target string   `json:"target"`
+		Format string   `json:"format"`
+		APIKey *string  `json:"api_key"`
+	}
+	var got libreRequest
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/translate" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s %s with content type %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		got = libreRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		if slices.Contains(got.Q, "rechazar") {
+			http.Error(w, `{"error": "Invalid request"}`, http.StatusBadRequest)
+			return
+		}
+		var translated []string
+		for _, text := range got.Q {
+			translated = append(translated, strings.ToUpper(text))
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"translatedText": translated})
+	}))
+	defer srv.Close()
+
+	config := DefaultConfig()
+	config.Translation.Provider = ProviderLibreTranslate
+	config.Translation.LibreTranslateURL = srv.URL + "/"
+	tr := NewTranslator(config, srv.Client())
+	if tr.Name() != ProviderLibreTranslate {
+		t.Fatalf("NewTranslator built %s", tr.Name())
+	}
+
+	translated, err := tr.Translate(context.Background(), []string{"hola", "adiós"}, "ES", "RU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(translated, []string{"HOLA", "ADIÓS"}) {
+		t.Errorf("translations = %q", translated)
+	}
+	if got.Source != "es" || got.Target != "ru" || got.Format != "text" || got.APIKey != nil {
+		t.Errorf("request = %+v, want lower-case es to ru as text without an API key", got)
+	}
+
+	// Without a source language the server detects it, and the key is sent when set
+	config.LibreTranslateAPIKey = "secreto"
+	tr = NewTranslator(config, srv.Client())
+	if _, err := tr.Translate(context.Background(), []string{"hola"}, "", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "auto" || got.APIKey == nil || *got.APIKey != "secreto" {
+		t.Errorf("request = %+v, want source auto and the API key", got)
+	}
+
+	_, err = tr.Translate(context.Background(), []string{"rechazar"}, "es", "en")
+	var apiErr *translationAPIError
+	if !errors.As(err, &apiErr) || apiErr.Provider != "LibreTranslate" || apiErr.Status != http.StatusBadRequest || !strings.Contains(apiErr.Body, "Invalid request") {
+		t.Errorf("err = %v, want a LibreTranslate API error with status 400", err)
+	}
+
+	requests = 0
+	if translated, err := tr.Translate(context.Background(), nil, "es", "en"); err != nil || len(translated) != 0 || requests != 0 {
+		t.Errorf("empty batch = %q, %v after %d requests, want nothing sent", translated, err, requests)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cachedTranslation is one translated text in the cache
+type cachedTranslation struct {
+	Key      string    `json:"key"`
+	Text     string    `json:"text"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// TranslationCache is a persistent store of earlier translations, kept as a JSON file
+type TranslationCache struct {
+	path    string
+	ttl     time.Duration
+	entries map[string]cachedTranslation
+	hits    int
+	misses  int
+}
+
+// OpenTranslationCache loads the cache from path, dropping entries older than ttl.
+// A missing file is an empty cache.
+func OpenTranslationCache(path string, ttl time.Duration) (*TranslationCache, error) {
+	c := &TranslationCache{path: path, ttl: ttl, entries: make(map[string]cachedTranslation)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading translation cache: %v", err)
+	}
+
+	var entries []cachedTranslation
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing translation cache: %v", err)
+	}
+	for _, e := range entries {
+		if !c.expired(e, time.Now()) {
+			c.entries[e.Key] = e
+		}
+	}
+	return c, nil
+}
+
+// translationCacheKey identifies a translation by the source text's hash, both languages and the provider
+func translationCacheKey(text, sourceLang, targetLang, provider string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:]) + "|" + sourceLang + "|" + targetLang + "|" + provider
+}
+
+// expired reports whether an entry is older than the cache's time to live
+func (c *TranslationCache) expired(e cachedTranslation, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(e.CachedAt) > c.ttl
+}
+
+// get looks up a translation and counts the hit or miss
+func (c *TranslationCache) get(key string) (string, bool) {
+	e, ok := c.entries[key]
+	if !ok || c.expired(e, time.Now()) {
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	return e.Text, true
+}
+
+// put stores a translation
+func (c *TranslationCache) put(key, text string) {
+	c.entries[key] = cachedTranslation{Key: key, Text: text, CachedAt: time.Now()}
+}
+
+// TakeStats returns the hits and misses since the last call and resets them
+func (c *TranslationCache) TakeStats() (hits, misses int) {
+	hits, misses = c.hits, c.misses
+	c.hits, c.misses = 0, 0
+	return hits, misses
+}
+
+// Save writes the unexpired entries back to disk, replacing the file atomically
+func (c *TranslationCache) Save() error {
+	now := time.Now()
+	entries := make([]cachedTranslation, 0, len(c.entries))
+	for _, e := range c.entries {
+		if !c.expired(e, now) {
+			entries = append(entries, e)
+		}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".translation-cache-*")
+	if err != nil {
+		return fmt.Errorf("error saving translation cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving translation cache: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving translation cache: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("error saving translation cache: %v", err)
+	}
+	return nil
+}
+
+// cachedTranslator consults the cache before calling the wrapped translator
+// and only sends it the texts that were not translated before
+type cachedTranslator struct {
+	Translator
+	cache *TranslationCache
+}
+
+// DetectedLanguage asks the wrapped translator; texts served from the cache have none
+func (t *cachedTranslator) DetectedLanguage(text string) string {
+	if detector, ok := t.Translator.(languageDetector); ok {
+		return detector.DetectedLanguage(text)
+	}
+	return ""
+}
+
+// Translate returns cached translations and fills in the rest from the wrapped translator
+func (t *cachedTranslator) Translate(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	results := make([]string, len(texts))
+	keys := make([]string, len(texts))
+
+	var missing []int
+	var missingTexts []string
+	for i, text := range texts {
+		keys[i] = translationCacheKey(text, sourceLang, targetLang, t.Name())
+		if cached, ok := t.cache.get(keys[i]); ok {
+			results[i] = cached
+			continue
+		}
+		missing = append(missing, i)
+		missingTexts = append(missingTexts, text)
+	}
+
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	translated, err := t.Translator.Translate(ctx, missingTexts, sourceLang, targetLang)
+	var partial *partialTranslationError
+	if errors.As(err, &partial) && len(partial.Done) == len(missingTexts) {
+		// Report the cached texts as translated along with those the translator managed
+		done := make([]bool, len(texts))
+		for i := range texts {
+			done[i] = true
+		}
+		for j, i := range missing {
+			results[i], done[i] = partial.Translations[j], partial.Done[j]
+			if partial.Done[j] {
+				t.cache.put(keys[i], partial.Translations[j])
+			}
+		}
+		return nil, &partialTranslationError{Translations: results, Done: done, Err: partial.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(translated) != len(missingTexts) {
+		return nil, fmt.Errorf("%s returned %d translations for %d texts", t.Name(), len(translated), len(missingTexts))
+	}
+
+	for j, i := range missing {
+		results[i] = translated[j]
+		t.cache.put(keys[i], translated[j])
+	}
+	return results, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachedTranslator(t *testing.T) {
+	errDown := errors.New("translator down")
+	tests := []struct {
+		name       string
+		cached     []string // Texts translated es -> ru before the call
+		texts      []string
+		sourceLang string
+		fail       func(texts []string) error
+		want       []string
+		wantSent   []string
+		wantErr    bool
+		wantCached []string // Texts es -> ru in the cache after the call
+	}{
+		{
+			name: "all cached", cached: []string{"hola", "adiós"},
+			texts: []string{"hola", "adiós"}, sourceLang: "es",
+			want: []string{"HOLA", "ADIÓS"}, wantCached: []string{"hola", "adiós"},
+		},
+		{
+			name: "only misses sent", cached: []string{"adiós"},
+			texts: []string{"hola", "adiós", "gracias"}, sourceLang: "es",
+			want: []string{"HOLA", "ADIÓS", "GRACIAS"}, wantSent: []string{"hola", "gracias"}, wantCached: []string{"hola", "adiós", "gracias"},
+		},
+		{
+			name: "keyed by source language", cached: []string{"hola"},
+			texts: []string{"hola"}, sourceLang: "",
+			want: []string{"HOLA"}, wantSent: []string{"hola"}, wantCached: []string{"hola"},
+		},
+		{
+			name: "failure caches nothing", cached: []string{"adiós"},
+			texts: []string{"hola", "adiós"}, sourceLang: "es",
+			fail:    func([]string) error { return errDown },
+			wantErr: true, wantSent: []string{"hola"}, wantCached: []string{"adiós"},
+		},
+		{
+			name: "partial caches what was translated", cached: []string{"adiós"},
+			texts: []string{"hola", "adiós", "gracias"}, sourceLang: "es",
+			fail: func(texts []string) error {
+				return &partialTranslationError{Translations: []string{"HOLA", "gracias"}, Done: []bool{true, false}, Err: errTranslationBudget}
+			},
+			wantErr: true, wantSent: []string{"hola", "gracias"}, wantCached: []string{"hola", "adiós"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := OpenTranslationCache(filepath.Join(t.TempDir(), "cache.json"), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, text := range tt.cached {
+				cache.put(translationCacheKey(text, "es", "ru", "fake"), strings.ToUpper(text))
+			}
+
+			var sent []string
+			fake := &fakeTranslator{fail: func(texts []string) error {
+				sent = append(sent, texts...)
+				if tt.fail != nil {
+					return tt.fail(texts)
+				}
+				return nil
+			}}
+			tr := &cachedTranslator{Translator: fake, cache: cache}
+
+			got, err := tr.Translate(context.Background(), tt.texts, tt.sourceLang, "ru")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("translations = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("sent %q, want %q", sent, tt.wantSent)
+			}
+			for _, text := range []string{"hola", "adiós", "gracias"} {
+				_, ok := cache.entries[translationCacheKey(text, "es", "ru", "fake")]
+				if want := slices.Contains(tt.wantCached, text); ok != want {
+					t.Errorf("%q cached = %v, want %v", text, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedTranslatorReportsPartialResults(t *testing.T) {
+	cache, err := OpenTranslationCache(filepath.Join(t.TempDir(), "cache.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.put(translationCacheKey("adiós", "es", "ru", "fake"), "ADIÓS")
+	fake := &fakeTranslator{fail: func(texts []string) error {
+		return &partialTranslationError{Translations: []string{"HOLA", "gracias"}, Done: []bool{true, false}, Err: errTranslationBudget}
+	}}
+	tr := &cachedTranslator{Translator: fake, cache: cache}
+
+	_, err = tr.Translate(context.Background(), []string{"hola", "adiós", "gracias"}, "es", "ru")
+	var partial *partialTranslationError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want a partial translation", err)
+	}
+	if !slices.Equal(partial.Translations, []string{"HOLA", "ADIÓS", "gracias"}) || !slices.Equal(partial.Done, []bool{true, true, false}) {
+		t.Errorf("partial = %q %v, want the cached text counted as done", partial.Translations, partial.Done)
+	}
+	if !errors.Is(err, errTranslationBudget) {
+		t.Errorf("err = %v, want it to wrap %v", err, errTranslationBudget)
+	}
+	if hits, misses := cache.TakeStats(); hits != 1 || misses != 2 {
+		t.Errorf("stats = %d hits, %d misses, want 1 and 2", hits, misses)
+	}
+}
+
+func TestTranslationCacheExpiry(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "cache.json")
+	data, err := json.Marshal([]cachedTranslation{
+		{Key: "fresh", Text: "HOLA", CachedAt: now.Add(-time.Hour)},
+		{Key: "stale", Text: "ADIÓS", CachedAt: now.Add(-48 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want []string
+	}{
+		{"no ttl keeps everything", 0, []string{"fresh", "stale"}},
+		{"stale entries dropped", 24 * time.Hour, []string{"fresh"}},
+		{"all expired", 30 * time.Minute, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := OpenTranslationCache(path, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"fresh", "stale"} {
+				_, ok := cache.get(key)
+				if want := slices.Contains(tt.want, key); ok != want {
+					t.Errorf("get(%q) found = %v, want %v", key, ok, want)
+				}
+			}
+		})
+	}
+
+	// An entry that expires while the cache is open is a miss and is not saved
+	cache, err := OpenTranslationCache(path, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.entries["fresh"] = cachedTranslation{Key: "fresh", Text: "HOLA", CachedAt: now.Add(-25 * time.Hour)}
+	cache.put("new", "GRACIAS")
+	if _, ok := cache.get("fresh"); ok {
+		t.Error("an entry past its ttl was served")
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenTranslationCache(path, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.entries) != 1 {
+		t.Errorf("saved %d entries, want only the new one", len(reopened.entries))
+	}
+	if text, ok := reopened.get("new"); !ok || text != "GRACIAS" {
+		t.Errorf("get(new) = %q, %v after saving", text, ok)
+	}
+
+	if _, err := OpenTranslationCache(filepath.Join(t.TempDir(), "missing.json"), 0); err != nil {
+		t.Errorf("a missing cache file: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fetchTrendPage scrapes trending topics from a trends page, trying the
+// spec's selectors in order until one of them yields trends
+func (na *NewsAggregator) fetchTrendPage(ctx context.Context, spec TrendSpec) ([]string, error) {
+	doc, err := na.fetchDocument(ctx, spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var trends []string
+	for _, selector := range spec.Selectors {
+		doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+			if spec.MaxItems > 0 && len(trends) >= spec.MaxItems {
+				return
+			}
+			trend := strings.TrimSpace(s.Text())
+			// Skip truncated names and stray fragments
+			if len(trend) > 2 && !strings.Contains(trend, "...") {
+				trends = append(trends, trend)
+			}
+		})
+		if len(trends) > 0 {
+			break
+		}
+	}
+
+	return trends, nil
+}
+
+// trendMatcher finds the trending topics an item relates to
+type trendMatcher struct {
+	trends  []string // Trends as fetched, used for annotations
+	phrases []string // Normalized search phrase for each trend, empty when too short to match
+}
+
+// newTrendMatcher prepares the trends of a run for matching against items
+func newTrendMatcher(trends []string) *trendMatcher {
+	m := &trendMatcher{trends: trends, phrases: make([]string, len(trends))}
+	for i, trend := range trends {
+		phrase := normalizeWords(splitHashtag(trend))
+		if len([]rune(phrase)) >= 3 {
+			m.phrases[i] = phrase
+		}
+	}
+	return m
+}
+
+// Match returns the trends whose words appear in the item's title or description
+func (m *trendMatcher) Match(item NewsItem) []string {
+	if m == nil {
+		return nil
+	}
+	content := " " + normalizeWords(item.Title+" "+item.Description) + " "
+
+	var matched []string
+	for i, phrase := range m.phrases {
+		if phrase != "" && strings.Contains(content, " "+phrase+" ") {
+			matched = append(matched, m.trends[i])
+		}
+	}
+	return matched
+}
+
+// splitHashtag turns a hashtag into words: "#EleccionesGenerales" becomes
+// "Elecciones Generales" and "#PSOEGana2024" becomes "PSOE Gana 2024"
+func splitHashtag(trend string) string {
+	trend = strings.TrimSpace(trend)
+	if !strings.HasPrefix(trend, "#") {
+		return trend
+	}
+
+	runes := []rune(strings.TrimPrefix(trend, "#"))
+	var sb strings.Builder
+	for i, r := range runes {
+		if i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			switch {
+			case unicode.IsUpper(r) && unicode.IsLower(prev),
+				unicode.IsUpper(r) && unicode.IsUpper(prev) && nextLower,
+				unicode.IsDigit(r) && unicode.IsLetter(prev),
+				unicode.IsLetter(r) && unicode.IsDigit(prev):
+				sb.WriteRune(' ')
+			}
+		}
+		if r == '_' {
+			r = ' '
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// normalizeWords lower-cases and accent-folds text and joins its words with single spaces
+func normalizeWords(s string) string {
+	s = accentFolder.Replace(strings.ToLower(s))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
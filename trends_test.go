@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitHashtag(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"#EleccionesGenerales", "Elecciones Generales"},
+		{"#PSOEGana2024", "PSOE Gana 2024"},
+		{"#LaLiga", "La Liga"},
+		{"#Real_Madrid", "Real Madrid"},
+		{"#Champions2026Final", "Champions 2026 Final"},
+		{"#ÁngelMartín", "Ángel Martín"},
+		{"#madrid", "madrid"},
+		{"#DANA", "DANA"},
+		{"  #BarçaMadrid ", "Barça Madrid"},
+		{"Real Madrid", "Real Madrid"},
+		{"EleccionesGenerales", "EleccionesGenerales"},
+		{"#", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := splitHashtag(tt.in); got != tt.want {
+				t.Errorf("splitHashtag(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrendMatcherMatch(t *testing.T) {
+	m := newTrendMatcher([]string{"#EleccionesGenerales", "Real Madrid", "#PSOEGana2024", "Pérez", "#UE", "Barça"})
+	tests := []struct {
+		name string
+		item NewsItem
+		want []string
+	}{
+		{"hashtag words in the title", NewsItem{Title: "Las elecciones generales serán en julio"}, []string{"#EleccionesGenerales"}},
+		{"phrase in the description", NewsItem{Title: "Fútbol", Description: "El Real Madrid gana la Liga."}, []string{"Real Madrid"}},
+		{"accents folded", NewsItem{Title: "Declaraciones de Perez tras el pleno"}, []string{"Pérez"}},
+		{"same accents in the content", NewsItem{Title: "El BARÇA empata"}, []string{"Barça"}},
+		{"hashtag with digits", NewsItem{Title: "El PSOE gana 2024 votos más"}, []string{"#PSOEGana2024"}},
+		{"several trends", NewsItem{Title: "Elecciones generales: el Real Madrid y Pérez"}, []string{"#EleccionesGenerales", "Real Madrid", "Pérez"}},
+		{"whole words only", NewsItem{Title: "Madrileños en el Real Madridista club"}, nil},
+		{"part of the phrase", NewsItem{Title: "Elecciones en Andalucía"}, nil},
+		{"too short to match", NewsItem{Title: "La UE aprueba el plan"}, nil},
+		{"nothing", NewsItem{Title: "Sube el IPC"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Match(tt.item); !slices.Equal(got, tt.want) {
+				t.Errorf("Match(%q) = %q, want %q", tt.item.Title, got, tt.want)
+			}
+		})
+	}
+
+	var none *trendMatcher
+	if got := none.Match(NewsItem{Title: "Real Madrid"}); got != nil {
+		t.Errorf("nil matcher matched %q", got)
+	}
+}
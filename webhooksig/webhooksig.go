@@ -0,0 +1,131 @@
+// Package webhooksig signs and verifies the webhook requests of SpainHotNewsCrawler.
+//
+// A signed request carries two headers: X-Signature-Timestamp, the Unix time
+// in seconds at which it was sent, and X-Signature-256, "sha256=" followed by
+// the hex HMAC-SHA256 of the timestamp, a dot and the request body, keyed with
+// the shared secret. A receiver checks the signature and rejects requests
+// whose timestamp is too far from its own clock. That limits replays to the
+// tolerance window but does not stop them: a captured request can be sent
+// again until it expires. Receivers that must not act on a digest twice should
+// also drop requests whose Idempotency-Key header they have already seen; the
+// key stays the same across retries.
+package webhooksig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header names
+const (
+	SignatureHeader = "X-Signature-256"
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+// DefaultTolerance is how far a request's timestamp may be from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultMaxBodySize is the largest body, in bytes, VerifyRequest reads
+const DefaultMaxBodySize = 1 << 20
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("webhooksig: missing signature or timestamp header")
+	ErrInvalidSignature = errors.New("webhooksig: signature does not match")
+	ErrExpired          = errors.New("webhooksig: timestamp outside the allowed tolerance")
+	ErrBodyTooLarge     = errors.New("webhooksig: request body too large")
+)
+
+// Sign returns the signature header value for body sent at timestamp
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	return sign(secret, strconv.FormatInt(timestamp.Unix(), 10), body)
+}
+
+func sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the timestamp and signature headers of a request whose body is body
+func SignRequest(req *http.Request, secret []byte, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, sign(secret, timestamp, body))
+}
+
+// Verify checks a signature and timestamp, as read from the headers, against
+// body. A tolerance of 0 uses DefaultTolerance.
+func Verify(secret []byte, signature, timestamp string, body []byte, tolerance time.Duration, now time.Time) error {
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("webhooksig: invalid timestamp %q", timestamp)
+	}
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > tolerance || skew < -tolerance {
+		return ErrExpired
+	}
+
+	expected := sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyRequest reads and verifies the body of a signed request. The body is
+// returned and also put back on the request so later handlers can read it.
+// Bodies over maxBodySize bytes are not read past the limit and fail with
+// ErrBodyTooLarge; a maxBodySize of 0 uses DefaultMaxBodySize.
+func VerifyRequest(r *http.Request, secret []byte, tolerance time.Duration, maxBodySize int64) ([]byte, error) {
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	r.Body.Close()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, ErrBodyTooLarge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("webhooksig: error reading body: %v", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = Verify(secret, r.Header.Get(SignatureHeader), r.Header.Get(TimestampHeader), body, tolerance, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Middleware rejects requests without a valid signature with 401, and bodies
+// over maxBodySize bytes with 413, before they reach next
+func Middleware(secret []byte, tolerance time.Duration, maxBodySize int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := VerifyRequest(r, secret, tolerance, maxBodySize); errors.Is(err, ErrBodyTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
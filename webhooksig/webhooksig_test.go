@@ -0,0 +1,120 @@
+package webhooksig
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("s3cret")
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_790_000_000, 0)
+	body := []byte(`{"text":"TOP SPAIN NEWS"}`)
+	signed := Sign(secret, now, body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		signature string
+		timestamp string
+		body      []byte
+		now       time.Time
+		tolerance time.Duration
+		want      error
+	}{
+		{"valid", secret, signed, timestamp, body, now, 0, nil},
+		{"valid with surrounding space", secret, " " + signed + " ", timestamp, body, now, 0, nil},
+		{"tampered body", secret, signed, timestamp, []byte(`{"text":"FAKE NEWS"}`), now, 0, ErrInvalidSignature},
+		{"wrong secret", []byte("other"), signed, timestamp, body, now, 0, ErrInvalidSignature},
+		{"timestamp changed", secret, signed, strconv.FormatInt(now.Unix()+1, 10), body, now.Add(time.Second), 0, ErrInvalidSignature},
+		{"within tolerance", secret, signed, timestamp, body, now.Add(DefaultTolerance), 0, nil},
+		{"expired", secret, signed, timestamp, body, now.Add(DefaultTolerance + time.Second), 0, ErrExpired},
+		{"from the future", secret, signed, timestamp, body, now.Add(-DefaultTolerance - time.Second), 0, ErrExpired},
+		{"custom tolerance", secret, signed, timestamp, body, now.Add(2 * time.Minute), time.Minute, ErrExpired},
+		{"missing signature", secret, "", timestamp, body, now, 0, ErrMissingSignature},
+		{"missing timestamp", secret, signed, "", body, now, 0, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.signature, tt.timestamp, tt.body, tt.tolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := Verify(secret, signed, "yesterday", body, 0, now); err == nil {
+		t.Error("Verify accepted a timestamp that is not a number")
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"text":"TOP SPAIN NEWS"}`
+	req := httptest.NewRequest(http.MethodPost, "/digest", strings.NewReader(body))
+	SignRequest(req, secret, []byte(body), time.Now())
+
+	got, err := VerifyRequest(req, secret, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+	// The body is put back for the next handler
+	if again, _ := io.ReadAll(req.Body); string(again) != body {
+		t.Errorf("request body after verifying = %q, want %q", again, body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/digest", strings.NewReader(body))
+	SignRequest(req, secret, []byte(body), time.Now())
+	if _, err := VerifyRequest(req, secret, 0, 10); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("VerifyRequest over the size limit = %v, want %v", err, ErrBodyTooLarge)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := `{"text":"TOP SPAIN NEWS"}`
+	tests := []struct {
+		name        string
+		sign        func(*http.Request)
+		maxBodySize int64
+		want        int
+	}{
+		{"signed", func(r *http.Request) { SignRequest(r, secret, []byte(body), time.Now()) }, 0, http.StatusOK},
+		{"unsigned", func(r *http.Request) {}, 0, http.StatusUnauthorized},
+		{"wrong secret", func(r *http.Request) { SignRequest(r, []byte("other"), []byte(body), time.Now()) }, 0, http.StatusUnauthorized},
+		{"tampered body", func(r *http.Request) { SignRequest(r, secret, []byte(body+" "), time.Now()) }, 0, http.StatusUnauthorized},
+		{"stale", func(r *http.Request) { SignRequest(r, secret, []byte(body), time.Now().Add(-time.Hour)) }, 0, http.StatusUnauthorized},
+		{"too large", func(r *http.Request) { SignRequest(r, secret, []byte(body), time.Now()) }, 10, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				received = string(b)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/digest", strings.NewReader(body))
+			tt.sign(req)
+			rec := httptest.NewRecorder()
+			Middleware(secret, DefaultTolerance, tt.maxBodySize, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && received != body {
+				t.Errorf("handler got body %q, want %q", received, body)
+			}
+			if tt.want != http.StatusOK && received != "" {
+				t.Error("a rejected request reached the handler")
+			}
+		})
+	}
+}